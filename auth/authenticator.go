@@ -0,0 +1,16 @@
+package auth
+
+import (
+	"github.com/f-chilmi/just-text-go/repository"
+)
+
+// Authenticator issues and verifies the access tokens with the key set and
+// checks them against the revocation list of the token repository.
+type Authenticator struct {
+	keys   *KeySet
+	tokens repository.TokenRepository
+}
+
+func NewAuthenticator(keys *KeySet, tokens repository.TokenRepository) *Authenticator {
+	return &Authenticator{keys: keys, tokens: tokens}
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// kid of the key built from the legacy SECRET_KEY variable, also used to
+// verify tokens issued before they carried a kid header
+const legacyKeyID = "default"
+
+// Key is one entry of the key set. Verify-only keys (a public key without
+// its private part) are kept around to accept tokens of a retired key.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeySet signs with the active key and verifies with the key named by the
+// kid header, so the active key can be rotated without logging anyone out.
+type KeySet struct {
+	active string
+	keys   map[string]*Key
+}
+
+func NewKeySet(active string, list ...*Key) (*KeySet, error) {
+	if len(list) == 0 {
+		return nil, errors.New("no jwt key configured")
+	}
+
+	ks := &KeySet{keys: make(map[string]*Key)}
+	for _, k := range list {
+		if _, ok := ks.keys[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate jwt key id %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+	}
+
+	if active == "" {
+		active = list[0].ID
+	}
+	k, ok := ks.keys[active]
+	if !ok {
+		return nil, fmt.Errorf("active jwt key %q is not configured", active)
+	}
+	if k.signKey == nil {
+		return nil, fmt.Errorf("active jwt key %q cannot sign", active)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+// LoadKeys builds the key set from the environment:
+//
+//	JWT_KEYS=kid:HS256:ENV_VAR_WITH_SECRET,kid:RS256:/path/key.pem,kid:EdDSA:/path/key.pem
+//	JWT_ACTIVE_KEY=kid (defaults to the first key)
+//
+// Without JWT_KEYS the HS256 SECRET_KEY is used. It fails when no key is
+// configured at all.
+func LoadKeys() (*KeySet, error) {
+	var list []*Key
+
+	spec := strings.TrimSpace(os.Getenv("JWT_KEYS"))
+	if spec == "" {
+		secret := os.Getenv("SECRET_KEY")
+		if secret == "" {
+			return nil, errors.New("no jwt key configured, set JWT_KEYS or SECRET_KEY")
+		}
+		list = append(list, NewHMACKey(legacyKeyID, []byte(secret)))
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		k, err := parseKeySpec(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, k)
+	}
+
+	return NewKeySet(os.Getenv("JWT_ACTIVE_KEY"), list...)
+}
+
+func parseKeySpec(entry string) (*Key, error) {
+	parts := strings.SplitN(entry, ":", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return nil, fmt.Errorf("invalid jwt key %q, expected kid:alg:source", entry)
+	}
+	kid, alg, source := parts[0], parts[1], parts[2]
+
+	if alg == jwt.SigningMethodHS256.Alg() {
+		secret := os.Getenv(source)
+		if secret == "" {
+			return nil, fmt.Errorf("jwt key %q: %s is empty", kid, source)
+		}
+		return NewHMACKey(kid, []byte(secret)), nil
+	}
+
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("jwt key %q: %v", kid, err)
+	}
+
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		return parseRSAKey(kid, data)
+	case SigningMethodEd25519.Alg():
+		return parseEdDSAKey(kid, data)
+	default:
+		return nil, fmt.Errorf("jwt key %q: unsupported algorithm %s", kid, alg)
+	}
+}
+
+func NewHMACKey(kid string, secret []byte) *Key {
+	return &Key{ID: kid, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+func parseRSAKey(kid string, data []byte) (*Key, error) {
+	k := &Key{ID: kid, Method: jwt.SigningMethodRS256}
+
+	if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		k.signKey = private
+		k.verifyKey = &private.PublicKey
+		return k, nil
+	}
+
+	public, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("jwt key %q: %v", kid, err)
+	}
+	k.verifyKey = public
+	return k, nil
+}
+
+func parseEdDSAKey(kid string, data []byte) (*Key, error) {
+	k := &Key{ID: kid, Method: SigningMethodEd25519}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwt key %q: not a pem file", kid)
+	}
+
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		private, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("jwt key %q: not an ed25519 key", kid)
+		}
+		k.signKey = private
+		k.verifyKey = private.Public()
+		return k, nil
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("jwt key %q: %v", kid, err)
+	}
+	public, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("jwt key %q: not an ed25519 key", kid)
+	}
+	k.verifyKey = public
+	return k, nil
+}
+
+// Sign signs the claims with the active key and sets its kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	k := ks.keys[ks.active]
+
+	token := jwt.NewWithClaims(k.Method, claims)
+	token.Header["kid"] = k.ID
+
+	return token.SignedString(k.signKey)
+}
+
+// Keyfunc picks the verification key of the token's kid, refusing any
+// algorithm other than the one the key was configured with.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = legacyKeyID
+	}
+
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return k.verifyKey, nil
+}
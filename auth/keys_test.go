@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func pemBlock(t *testing.T, kind string, der []byte, err error) []byte {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der})
+}
+
+// rsaKeys returns the pem of a new rsa private key and of its public key.
+func rsaKeys(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	return pemBlock(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(private), nil), pemBlock(t, "PUBLIC KEY", public, err)
+}
+
+// ed25519Keys returns the pem of a new ed25519 private key and of its
+// public key.
+func ed25519Keys(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDer, err := x509.MarshalPKCS8PrivateKey(private)
+	privatePem := pemBlock(t, "PRIVATE KEY", privateDer, err)
+	publicDer, err := x509.MarshalPKIXPublicKey(public)
+	return privatePem, pemBlock(t, "PUBLIC KEY", publicDer, err)
+}
+
+func rsaKey(t *testing.T, kid string, data []byte) *Key {
+	t.Helper()
+
+	k, err := parseRSAKey(kid, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func edKey(t *testing.T, kid string, data []byte) *Key {
+	t.Helper()
+
+	k, err := parseEdDSAKey(kid, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func mustKeySet(t *testing.T, active string, list ...*Key) *KeySet {
+	t.Helper()
+
+	ks, err := NewKeySet(active, list...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+// signHS256 signs the claims with secret, whatever key the kid names.
+func signHS256(t *testing.T, kid string, secret []byte) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": 1})
+	token.Header["kid"] = kid
+	s, err := token.SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestUnknownKeyIdIsRejected(t *testing.T) {
+	secret := []byte("secret")
+	ks := mustKeySet(t, "", NewHMACKey("current", secret))
+
+	if _, err := jwt.Parse(signHS256(t, "current", secret), ks.Keyfunc); err != nil {
+		t.Fatalf("expected the token of a known kid to verify, got %v", err)
+	}
+	if _, err := jwt.Parse(signHS256(t, "other", secret), ks.Keyfunc); err == nil {
+		t.Fatal("expected the token of an unknown kid to be rejected")
+	}
+	// without a kid the legacy key is looked up, and it is not configured
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": 1})
+	s, err := token.SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(s, ks.Keyfunc); err == nil {
+		t.Fatal("expected the token without a kid to be rejected")
+	}
+}
+
+func TestPublicKeyAsHMACSecretIsRejected(t *testing.T) {
+	_, rsaPublic := rsaKeys(t)
+	_, edPublic := ed25519Keys(t)
+	ks := mustKeySet(t, "hmac",
+		NewHMACKey("hmac", []byte("secret")),
+		rsaKey(t, "rsa", rsaPublic),
+		edKey(t, "ed", edPublic),
+	)
+
+	// the public keys are known to anyone, they must never act as a secret:
+	// the token is refused by the algorithm of the kid, before any signature
+	// check
+	raw := ks.keys["ed"].verifyKey.(ed25519.PublicKey)
+	for _, c := range []struct {
+		kid    string
+		secret []byte
+	}{{"rsa", rsaPublic}, {"ed", edPublic}, {"ed", raw}} {
+		_, err := jwt.Parse(signHS256(t, c.kid, c.secret), ks.Keyfunc)
+		ve, ok := err.(*jwt.ValidationError)
+		if !ok || ve.Errors&jwt.ValidationErrorUnverifiable == 0 {
+			t.Fatalf("expected a HS256 token signed with the %s public key to be refused by the key set, got %v", c.kid, err)
+		}
+	}
+}
+
+func TestRetiredKeyStillVerifies(t *testing.T) {
+	rsaPrivate, rsaPublic := rsaKeys(t)
+	edPrivate, _ := ed25519Keys(t)
+
+	before := mustKeySet(t, "old", rsaKey(t, "old", rsaPrivate))
+	old, err := before.Sign(jwt.MapClaims{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the old key is only kept to verify, the new one signs
+	after := mustKeySet(t, "new", edKey(t, "new", edPrivate), rsaKey(t, "old", rsaPublic))
+	if _, err := jwt.Parse(old, after.Keyfunc); err != nil {
+		t.Fatalf("expected the token of the retired key to verify, got %v", err)
+	}
+
+	current, err := after.Sign(jwt.MapClaims{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Parse(current, after.Keyfunc)
+	if err != nil {
+		t.Fatalf("expected the token of the active key to verify, got %v", err)
+	}
+	if token.Header["kid"] != "new" {
+		t.Fatalf("expected new tokens to be signed by the active key, got kid %v", token.Header["kid"])
+	}
+
+	if _, err := NewKeySet("old", rsaKey(t, "old", rsaPublic)); err == nil {
+		t.Fatal("expected a verify-only key to be refused as the active key")
+	}
+}
+
+func TestEdDSATokenRoundTrip(t *testing.T) {
+	edPrivate, _ := ed25519Keys(t)
+	ks := mustKeySet(t, "", edKey(t, "ed", edPrivate))
+
+	signed, err := ks.Sign(jwt.MapClaims{"id": 7, "username": "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.Parse(signed, ks.Keyfunc)
+	if err != nil {
+		t.Fatalf("expected the EdDSA token to verify, got %v", err)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if token.Header["alg"] != "EdDSA" || claims["id"] != float64(7) || claims["username"] != "alice" {
+		t.Fatalf("expected the EdDSA token with its claims, got %v %v", token.Header, claims)
+	}
+
+	// any other signature is refused
+	tampered := signed[:len(signed)-4] + "AAAA"
+	if tampered == signed {
+		tampered = signed[:len(signed)-4] + "BBBB"
+	}
+	if _, err := jwt.Parse(tampered, ks.Keyfunc); err == nil {
+		t.Fatal("expected a tampered EdDSA token to be rejected")
+	}
+}
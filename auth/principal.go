@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var errUnauthorized = errors.New("unauthorized token")
+
+// Principal is the authenticated identity of a request, parsed once by the
+// auth middleware.
+type Principal struct {
+	ID        int64
+	Phone     string
+	Username  string
+	TokenID   string
+	Scopes    []string
+	IssuedAt  int64
+	ExpiresAt int64
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey int
+
+const principalKey contextKey = iota
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	return p, ok && p != nil
+}
+
+// CurrentUser returns the principal stored by the auth middleware, routes
+// without the middleware have none.
+func CurrentUser(r *http.Request) (*Principal, error) {
+	if p, ok := PrincipalFromContext(r.Context()); ok {
+		return p, nil
+	}
+	return nil, errUnauthorized
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/f-chilmi/just-text-go/models"
+)
+
+var errRevokedToken = errors.New("token revoked")
+
+// RevokeToken revokes the session of the access token, it is kept in the
+// revocation list until the token would have expired anyway.
+func (a *Authenticator) RevokeToken(p *Principal) error {
+	if p.TokenID == "" {
+		return errors.New("token has no id")
+	}
+
+	return a.tokens.RevokeToken(models.RevokedToken{
+		Jti:       p.TokenID,
+		IdUser:    p.ID,
+		ExpiresAt: time.Unix(p.ExpiresAt, 0).UTC(),
+	})
+}
+
+// RevokeAllTokens revokes every access token of the user issued until now.
+func (a *Authenticator) RevokeAllTokens(idUser int64) error {
+	// iat has a one second resolution, tokens issued in the same second are revoked too
+	now := time.Now().UTC().Truncate(time.Second)
+	return a.tokens.RevokeUserTokens(idUser, now, now.Add(AccessTokenTTL))
+}
+
+func (a *Authenticator) checkRevoked(p *Principal) error {
+	revoked, err := a.tokens.IsRevoked(p.TokenID, p.ID, time.Unix(p.IssuedAt, 0).UTC())
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errRevokedToken
+	}
+	return nil
+}
+
+// CollectRevokedTokens periodically removes the revocation entries of
+// tokens that have expired, it is meant to run in its own goroutine.
+func (a *Authenticator) CollectRevokedTokens(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := a.tokens.DeleteExpired(time.Now().UTC())
+		if err != nil {
+			log.Printf("unable to collect revoked tokens. %v", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("collected %d revoked tokens", deleted)
+		}
+	}
+}
@@ -1,12 +1,8 @@
 package auth
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -16,8 +12,6 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-var secretkey = os.Getenv("SECRET_KEY")
-
 func CheckPasswordHash(password, hash string) error {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err
@@ -28,50 +22,41 @@ func GeneratehashPassword(password string) (string, error) {
 	return string(bytes), err
 }
 
-func GenerateJWT(id int64, username string, phone string) (models.GenerateTokenRes, string, error) {
-	var mySigningKey = []byte(secretkey)
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
+// lifetime of an access token
+const AccessTokenTTL = time.Minute * 30
+
+func (a *Authenticator) GenerateJWT(id int64, username string, phone string) (models.GenerateTokenRes, string, error) {
+	claims := jwt.MapClaims{}
+
+	// jti identifies the session so it can be revoked on logout
+	jti, err := randomString(16)
+	if err != nil {
+		return models.GenerateTokenRes{}, "", err
+	}
+
+	now := time.Now()
+	exp := now.Add(AccessTokenTTL).Unix()
 
 	claims["id"] = id
 	claims["phone"] = phone
 	claims["username"] = username
-	claims["exp"] = time.Now().Add(time.Minute * 30).Unix()
+	claims["jti"] = jti
+	claims["iat"] = now.Unix()
+	claims["exp"] = exp
 
-	tokenString, err := token.SignedString(mySigningKey)
+	tokenString, err := a.keys.Sign(claims)
 
 	var res models.GenerateTokenRes
 	res.ID = id
 	res.Phone = phone
 	res.Username = username
-	res.Exp = time.Now().Add(time.Minute * 30).Unix()
+	res.Exp = exp
 
 	return res, tokenString, err
 }
 
-func TokenValid(r *http.Request) error {
-	tokenString := ExtractToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
-	if err != nil {
-		return err
-	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		Pretty(claims)
-	}
-	return nil
-}
-
+// ExtractToken returns the token of the bearer Authorization header.
 func ExtractToken(r *http.Request) string {
-	keys := r.URL.Query()
-	token := keys.Get("token")
-	if token != "" {
-		return token
-	}
 	bearerToken := r.Header.Get("Authorization")
 	if len(strings.Split(bearerToken, " ")) == 2 {
 		return strings.Split(bearerToken, " ")[1]
@@ -79,44 +64,75 @@ func ExtractToken(r *http.Request) string {
 	return ""
 }
 
-func ExtracTokenID(r *http.Request) (int64, error) {
-	if r.Header["Authorization"] == nil {
-		err := errors.New("unauthorized token")
-		return 0, err
+// ExtractStreamToken also accepts the ?token= query, websocket and sse
+// clients cannot set headers. It must not be used for the other routes, the
+// query ends up in access logs and Referer headers.
+func ExtractStreamToken(r *http.Request) string {
+	if token := ExtractToken(r); token != "" {
+		return token
 	}
+	return r.URL.Query().Get("token")
+}
 
-	bearerToken := r.Header.Get("Authorization")
-	if len(strings.Split(bearerToken, " ")) == 2 {
-		bearerToken = strings.Split(bearerToken, " ")[1]
+// Authenticate parses and verifies the token of the Authorization header
+// and checks it has not been revoked.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	return a.authenticate(ExtractToken(r))
+}
+
+// AuthenticateStream is Authenticate for the websocket and sse routes,
+// where the token can be passed in the query.
+func (a *Authenticator) AuthenticateStream(r *http.Request) (*Principal, error) {
+	return a.authenticate(ExtractStreamToken(r))
+}
+
+func (a *Authenticator) authenticate(tokenString string) (*Principal, error) {
+	if tokenString == "" {
+		return nil, errUnauthorized
 	}
 
-	token, err := jwt.Parse(bearerToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return 0, errors.New("error while parsing")
-		}
-		return []byte(secretkey), nil
-	})
+	token, err := jwt.Parse(tokenString, a.keys.Keyfunc)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errUnauthorized
+	}
 
-	if ok && token.Valid {
-		uid, err := strconv.ParseInt(fmt.Sprintf("%.0f", claims["id"]), 10, 64)
-		if err != nil {
-			return 0, err
-		}
-		return uid, err
+	p, err := principalFromClaims(claims)
+	if err != nil {
+		return nil, err
 	}
-	return 0, err
+
+	err = a.checkRevoked(p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
-func Pretty(data interface{}) {
-	b, err := json.MarshalIndent(data, "", " ")
+func principalFromClaims(claims jwt.MapClaims) (*Principal, error) {
+	uid, err := strconv.ParseInt(fmt.Sprintf("%.0f", claims["id"]), 10, 64)
 	if err != nil {
-		log.Println(err)
-		return
+		return nil, err
+	}
+
+	p := &Principal{ID: uid}
+	p.Phone, _ = claims["phone"].(string)
+	p.Username, _ = claims["username"].(string)
+
+	// tokens issued before sessions existed have no jti nor iat
+	p.TokenID, _ = claims["jti"].(string)
+	if iat, ok := claims["iat"].(float64); ok {
+		p.IssuedAt = int64(iat)
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		p.ExpiresAt = int64(exp)
+	}
+	if scope, ok := claims["scope"].(string); ok {
+		p.Scopes = strings.Fields(scope)
 	}
 
-	fmt.Println(string(b))
+	return p, nil
 }
@@ -0,0 +1,327 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/models"
+	"github.com/f-chilmi/just-text-go/responses"
+	"github.com/f-chilmi/just-text-go/storage"
+	"github.com/gorilla/mux"
+)
+
+// maximum number of attachments of a single message
+const maxMsgAttachments = 10
+
+// room left in an upload request for the multipart framing around the file
+const multipartOverhead = 1 << 20
+
+// UploadAttachment stores the "file" field of a multipart form for the room,
+// the returned attachment id can then be sent with a message.
+func (s *Server) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	idRoom, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxAttachmentSize+multipartOverhead)
+	part, err := filePart(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	defer part.Close()
+
+	key, err := newBlobKey(idRoom)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the content is hashed, measured and sniffed while it is stored
+	hash := sha256.New()
+	meta := &uploadMeta{}
+	content := io.TeeReader(io.LimitReader(part, s.config.MaxAttachmentSize+1), io.MultiWriter(hash, meta))
+
+	err = s.blobs.Put(key, content)
+	if err == nil && meta.size > s.config.MaxAttachmentSize {
+		s.deleteBlob(key)
+		err = errFileTooLarge
+	}
+	switch {
+	case err == errFileTooLarge:
+		responses.ERROR(w, http.StatusRequestEntityTooLarge, fmt.Errorf("the file is larger than %d bytes", s.config.MaxAttachmentSize))
+		return
+	case err != nil:
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	case meta.size == 0:
+		s.deleteBlob(key)
+		responses.ERROR(w, http.StatusBadRequest, errors.New("the file is empty"))
+		return
+	}
+
+	attachment := models.Attachment{
+		IdRoom:     idRoom,
+		IdUploader: me.ID,
+		FileName:   cleanFileName(part.FileName()),
+		MimeType:   http.DetectContentType(meta.head),
+		Size:       meta.size,
+		Checksum:   hex.EncodeToString(hash.Sum(nil)),
+		StorageKey: key,
+	}
+	if models.HasThumbnail(attachment.MimeType) {
+		attachment.ThumbnailStatus = models.ThumbnailPending
+	}
+
+	attachment, err = s.attachments.InsertAttachment(attachment)
+	if err != nil {
+		s.deleteBlob(key)
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	attachment.WithURLs()
+
+	if attachment.ThumbnailStatus == models.ThumbnailPending {
+		s.thumbs.Enqueue(attachment.ID)
+	}
+
+	responses.JSON(w, http.StatusOK, attachment)
+}
+
+// DownloadAttachment serves the content of an attachment.
+func (s *Server) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := s.requestedAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	// only images are shown inline, anything else is downloaded
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.MimeType, "image/") {
+		disposition = "inline"
+	}
+
+	// the content never changes, the checksum is a strong etag
+	disposition = mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName})
+	s.serveBlob(w, r, attachment.StorageKey, attachment.MimeType, disposition, attachment.Size, `"`+attachment.Checksum+`"`)
+}
+
+// DownloadThumbnail serves the thumbnail of an image attachment once the
+// worker has made it.
+func (s *Server) DownloadThumbnail(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := s.requestedAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	if attachment.ThumbnailStatus != models.ThumbnailReady {
+		responses.ERROR(w, http.StatusNotFound, errors.New("thumbnail not found"))
+		return
+	}
+
+	s.serveBlob(w, r, attachment.ThumbnailKey, attachment.ThumbnailMimeType(), "inline", -1, `"`+attachment.Checksum+`-thumb"`)
+}
+
+func (s *Server) requestedAttachment(w http.ResponseWriter, r *http.Request) (models.Attachment, bool) {
+	idAttachment, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return models.Attachment{}, false
+	}
+
+	attachment, err := s.attachments.GetAttachment(idAttachment)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return models.Attachment{}, false
+	}
+	return attachment, true
+}
+
+// serveBlob writes an immutable blob, answering 304 to a client that
+// already has it. size is -1 when it is not known.
+func (s *Server) serveBlob(w http.ResponseWriter, r *http.Request, key string, contentType string, disposition string, size int64, etag string) {
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	blob, err := s.blobs.Open(key)
+	switch err {
+	case nil:
+		break
+	case storage.ErrNotFound:
+		responses.ERROR(w, http.StatusNotFound, errors.New("attachment not found"))
+		return
+	default:
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", disposition)
+	if size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, blob); err != nil {
+		log.Printf("unable to send blob %s. %v", key, err)
+	}
+}
+
+// checkAttachments makes sure every attachment can be sent by the user in
+// the room: uploaded by them into it and not sent with another message.
+func (s *Server) checkAttachments(ids []int64, idRoom int64, idUser int64) error {
+	if len(ids) > maxMsgAttachments {
+		return fmt.Errorf("a message can have at most %d attachments", maxMsgAttachments)
+	}
+
+	seen := make(map[int64]bool)
+	for _, id := range ids {
+		if seen[id] {
+			return fmt.Errorf("attachment %d is listed twice", id)
+		}
+		seen[id] = true
+
+		attachment, err := s.attachments.GetAttachment(id)
+		if err != nil || attachment.IdRoom != idRoom || attachment.IdUploader != idUser || attachment.IdMessage != 0 {
+			return fmt.Errorf("attachment %d cannot be sent", id)
+		}
+	}
+	return nil
+}
+
+// withAttachments fills the attachments of every message.
+func (s *Server) withAttachments(chats []models.Message) error {
+	if len(chats) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(chats))
+	for _, chat := range chats {
+		ids = append(ids, chat.ID)
+	}
+
+	attachments, err := s.attachments.ListAttachments(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range chats {
+		list := attachments[chats[i].ID]
+		for j := range list {
+			list[j].WithURLs()
+		}
+		chats[i].Attachments = list
+	}
+	return nil
+}
+
+// deleteMsgAttachments drops the attachments of a message deleted for
+// everyone along with their content.
+func (s *Server) deleteMsgAttachments(idMessage int64) error {
+	attachments, err := s.attachments.DeleteMsgAttachments(idMessage)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range attachments {
+		s.deleteBlob(a.StorageKey)
+		if a.ThumbnailKey != "" {
+			s.deleteBlob(a.ThumbnailKey)
+		}
+	}
+	return nil
+}
+
+// deleteBlob only logs failures, an orphan blob is harmless.
+func (s *Server) deleteBlob(key string) {
+	if err := s.blobs.Delete(key); err != nil {
+		log.Printf("unable to delete blob %s. %v", key, err)
+	}
+}
+
+var errFileTooLarge = errors.New("file too large")
+
+// uploadMeta measures the uploaded content and keeps its first bytes to
+// detect its type.
+type uploadMeta struct {
+	size int64
+	head []byte
+}
+
+func (m *uploadMeta) Write(p []byte) (int, error) {
+	m.size += int64(len(p))
+	if n := 512 - len(m.head); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		m.head = append(m.head, p[:n]...)
+	}
+	return len(p), nil
+}
+
+// filePart returns the "file" part of the multipart request, the parts
+// before it are skipped.
+func filePart(r *http.Request) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("required file")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// newBlobKey returns a random key under the folder of the room.
+func newBlobKey(idRoom int64) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("rooms/%d/%s", idRoom, hex.EncodeToString(b)), nil
+}
+
+// cleanFileName keeps the base name sent by the client, without any path.
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		return "file"
+	}
+	return models.Truncate(name, 255)
+}
@@ -0,0 +1,93 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// upload sends content as the "file" field of a multipart form.
+func upload(t *testing.T, srv *httptest.Server, path string, token string, name string, content []byte) (int, interface{}) {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	form.Close()
+
+	req, err := http.NewRequest("POST", srv.URL+path, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var decoded interface{}
+	if err := json.NewDecoder(res.Body).Decode(&decoded); err != nil {
+		t.Fatalf("upload %s: invalid json. %v", name, err)
+	}
+	return res.StatusCode, decoded
+}
+
+// download only returns the status, the body is not json.
+func download(t *testing.T, srv *httptest.Server, path string, token string) int {
+	t.Helper()
+
+	req, err := http.NewRequest("GET", srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestUnsentAttachmentIsPrivate(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+	b := signUp(t, srv, "bob", "200")
+	ta, tb := a["token"].(string), b["token"].(string)
+
+	_, room := call(t, srv, "GET", "/phone/200", ta, nil)
+	idRoom := field(t, room, "id")
+
+	status, res := upload(t, srv, fmt.Sprintf("/room/%v/attachments", idRoom), ta, "notes.txt", []byte("draft notes"))
+	expectStatus(t, "upload", status, http.StatusOK, res)
+	path := fmt.Sprintf("/attachments/%v", field(t, res, "id"))
+
+	if status := download(t, srv, path, ta); status != http.StatusOK {
+		t.Fatalf("download as the uploader: expected 200, got %d", status)
+	}
+	if status := download(t, srv, path, tb); status != http.StatusNotFound {
+		t.Fatalf("download before it is sent: expected 404, got %d", status)
+	}
+	if status := download(t, srv, path+"/thumbnail", tb); status != http.StatusNotFound {
+		t.Fatalf("thumbnail before it is sent: expected 404, got %d", status)
+	}
+
+	status, res = call(t, srv, "POST", fmt.Sprintf("/msg/%v", idRoom), ta, map[string]interface{}{"content": "notes", "attachment_ids": []interface{}{field(t, res, "id")}})
+	expectStatus(t, "send", status, http.StatusOK, res)
+
+	if status := download(t, srv, path, tb); status != http.StatusOK {
+		t.Fatalf("download once it is sent: expected 200, got %d", status)
+	}
+}
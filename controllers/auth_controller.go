@@ -4,16 +4,18 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/f-chilmi/just-text-go/auth"
 	"github.com/f-chilmi/just-text-go/models"
 	"github.com/f-chilmi/just-text-go/responses"
 )
 
-func Login(w http.ResponseWriter, r *http.Request) {
-	userM := models.User{}
+func (s *Server) Login(w http.ResponseWriter, r *http.Request) {
+	userM := models.Credentials{}
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
@@ -33,7 +35,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// token, err := userM.Login(userM.Phone, userM.Password)
-	userExisted, err := userM.GetUserByPhone(userM.Phone)
+	userExisted, err := s.users.GetUserByPhone(userM.Phone)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
@@ -41,26 +43,198 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	err = auth.CheckPasswordHash(userM.Password, userExisted.Password)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// a login starts a new refresh token family
+	family, err := auth.NewTokenFamily()
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	res, err := s.issueTokens(userExisted, family, 0)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, res)
+}
+
+func (s *Server) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req models.ReqRefreshToken
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.RefreshToken == "" {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("required refresh_token"))
+		return
+	}
+
+	stored, err := s.tokens.GetRefreshToken(auth.HashRefreshToken(req.RefreshToken))
+	switch err {
+	case sql.ErrNoRows:
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("invalid refresh token"))
+		return
+	case nil:
+		break
+	default:
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// a rotated token presented again means it leaked, kill the whole family
+	if stored.RevokedAt.Valid {
+		err = s.tokens.RevokeFamily(stored.Family)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("refresh token reused, session revoked"))
+		return
+	}
+
+	if time.Now().UTC().After(stored.ExpiresAt) {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("refresh token expired"))
+		return
+	}
+
+	user, err := s.users.GetUser(stored.IdUser)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("invalid refresh token"))
+		return
+	}
+
+	res, err := s.issueTokens(user, stored.Family, stored.ID)
+	switch err {
+	// somebody rotated the same token in the meantime
+	case sql.ErrNoRows:
+		err = s.tokens.RevokeFamily(stored.Family)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("refresh token reused, session revoked"))
+		return
+	case nil:
+		break
+	default:
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, res)
+}
+
+// issueTokens creates an access token and a refresh token of the family,
+// replacing the refresh token idOld when it is not 0.
+func (s *Server) issueTokens(user models.User, family string, idOld int64) (models.ResLoginWithToken, error) {
+	var res models.ResLoginWithToken
+
+	refresh, hash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return res, err
+	}
+
+	newT := models.RefreshToken{
+		IdUser:    user.ID,
+		Family:    family,
+		TokenHash: hash,
+		ExpiresAt: time.Now().UTC().Add(auth.RefreshTokenTTL),
+	}
+	if idOld == 0 {
+		_, err = s.tokens.InsertRefreshToken(newT)
+	} else {
+		_, err = s.tokens.RotateRefreshToken(idOld, newT)
+	}
+	if err != nil {
+		return res, err
+	}
+
+	response, validToken, err := s.auth.GenerateJWT(user.ID, user.Username, user.Phone)
+	if err != nil {
+		return res, err
+	}
+
+	res = models.ResLoginWithToken{
+		ID:           user.ID,
+		Phone:        response.Phone,
+		Username:     user.Username,
+		Exp:          response.Exp,
+		Token:        validToken,
+		RefreshToken: refresh,
+		RefreshExp:   newT.ExpiresAt.Unix(),
+	}
+	return res, nil
+}
+
+// Logout revokes the session of the access token used for the request and,
+// when given, the refresh token family of the same session.
+func (s *Server) Logout(w http.ResponseWriter, r *http.Request) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	// the body is optional
+	var req models.ReqRefreshToken
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
 	}
 
-	response, validToken, err := auth.GenerateJWT(userExisted.ID, userExisted.Username, userM.Phone)
+	err = s.auth.RevokeToken(me)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.RefreshToken != "" {
+		stored, err := s.tokens.GetRefreshToken(auth.HashRefreshToken(req.RefreshToken))
+		if err == nil && stored.IdUser == me.ID {
+			err = s.tokens.RevokeFamily(stored.Family)
+		}
+		if err != nil && err != sql.ErrNoRows {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	res := basicRes{Message: "logged out"}
+	responses.JSON(w, http.StatusOK, res)
+}
+
+// LogoutAll revokes every access and refresh token of the user.
+func (s *Server) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, err)
+		return
 	}
 
-	res := models.ResLoginWithToken{
-		ID:       userExisted.ID,
-		Phone:    response.Phone,
-		Username: userExisted.Username,
-		Exp:      response.Exp,
-		Token:    validToken,
+	err = s.auth.RevokeAllTokens(me.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = s.tokens.RevokeUserRefreshTokens(me.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
 	}
 
+	res := basicRes{Message: "logged out from all sessions"}
 	responses.JSON(w, http.StatusOK, res)
 }
 
-func Register(w http.ResponseWriter, r *http.Request) {
-	userM := models.User{}
+func (s *Server) Register(w http.ResponseWriter, r *http.Request) {
+	userM := models.Credentials{}
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -80,7 +254,7 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = userM.GetUserByPhone(userM.Phone)
+	_, err = s.users.GetUserByPhone(userM.Phone)
 
 	switch err {
 	// if no user found, so user can create new (register)
@@ -89,6 +263,7 @@ func Register(w http.ResponseWriter, r *http.Request) {
 
 		if err != nil {
 			responses.ERROR(w, http.StatusBadRequest, err)
+			return
 		}
 
 		newU := models.User{
@@ -96,9 +271,10 @@ func Register(w http.ResponseWriter, r *http.Request) {
 			Phone:    userM.Phone,
 			Password: newP,
 		}
-		_, err = userM.InsertUser(newU)
+		_, err = s.users.InsertUser(newU)
 		if err != nil {
 			responses.ERROR(w, http.StatusBadRequest, err)
+			return
 		}
 
 		res := basicRes{Message: "user created successfully"}
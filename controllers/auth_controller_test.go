@@ -0,0 +1,75 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// refresh rotates the refresh token and returns the new one, empty when
+// the refresh was refused.
+func refresh(t *testing.T, srv *httptest.Server, token interface{}) (int, string) {
+	t.Helper()
+
+	status, res := call(t, srv, "POST", "/token/refresh", "", map[string]interface{}{"refresh_token": token})
+	if status != http.StatusOK {
+		return status, ""
+	}
+	rotated, _ := field(t, res, "refresh_token").(string)
+	if rotated == "" {
+		t.Fatalf("expected a new refresh token, got %v", res)
+	}
+	return status, rotated
+}
+
+func TestRefreshTokenIsUsedOnce(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+
+	status, first := refresh(t, srv, a["refresh_token"])
+	expectStatus(t, "refresh", status, http.StatusOK, nil)
+	if first == a["refresh_token"] {
+		t.Fatalf("expected the refresh token to be rotated")
+	}
+
+	status, second := refresh(t, srv, first)
+	expectStatus(t, "refresh the rotated token", status, http.StatusOK, nil)
+
+	status, _ = refresh(t, srv, first)
+	expectStatus(t, "refresh the rotated token again", status, http.StatusUnauthorized, nil)
+	status, _ = refresh(t, srv, second)
+	expectStatus(t, "refresh after a reuse", status, http.StatusUnauthorized, nil)
+}
+
+func TestRefreshTokenReuseRevokesTheFamily(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+
+	// another session of the same user is another family
+	_, other := call(t, srv, "POST", "/login", "", map[string]string{"phone": "100", "password": "secret"})
+
+	status, rotated := refresh(t, srv, a["refresh_token"])
+	expectStatus(t, "refresh", status, http.StatusOK, nil)
+
+	status, _ = refresh(t, srv, a["refresh_token"])
+	expectStatus(t, "replay the old token", status, http.StatusUnauthorized, nil)
+	status, _ = refresh(t, srv, rotated)
+	expectStatus(t, "refresh the latest token of the family", status, http.StatusUnauthorized, nil)
+
+	status, _ = refresh(t, srv, field(t, other, "refresh_token"))
+	expectStatus(t, "refresh the other session", status, http.StatusOK, nil)
+}
+
+func TestRevokedFamilyCannotRefresh(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+
+	status, rotated := refresh(t, srv, a["refresh_token"])
+	expectStatus(t, "refresh", status, http.StatusOK, nil)
+
+	status, res := call(t, srv, "POST", "/logout", a["token"].(string), map[string]string{"refresh_token": rotated})
+	expectStatus(t, "logout", status, http.StatusOK, res)
+
+	status, _ = refresh(t, srv, rotated)
+	expectStatus(t, "refresh after the logout", status, http.StatusUnauthorized, nil)
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config holds the settings of the handlers.
+type Config struct {
+	// how long after sending a message its sender can still edit it
+	EditWindow time.Duration
+	// how long after sending a message its sender can still delete it for
+	// everyone, deleting it for oneself is always possible
+	DeleteWindow time.Duration
+	// largest file accepted as an attachment, in bytes
+	MaxAttachmentSize int64
+	// longest message accepted, in characters
+	MaxMessageLength int
+}
+
+// ConfigFromEnv reads the config from the environment, the .env file must
+// already be loaded.
+func ConfigFromEnv() (Config, error) {
+	var cfg Config
+
+	var err error
+	if cfg.EditWindow, err = envDuration("MESSAGE_EDIT_WINDOW", 15*time.Minute); err != nil {
+		return cfg, err
+	}
+	if cfg.DeleteWindow, err = envDuration("MESSAGE_DELETE_WINDOW", time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxAttachmentSize, err = envInt64("MAX_ATTACHMENT_SIZE", 25<<20); err != nil {
+		return cfg, err
+	}
+	maxLength, err := envInt64("MESSAGE_MAX_LENGTH", 4096)
+	if err != nil {
+		return cfg, err
+	}
+	if maxLength < 1 {
+		return cfg, fmt.Errorf("invalid MESSAGE_MAX_LENGTH: %d", maxLength)
+	}
+	cfg.MaxMessageLength = int(maxLength)
+
+	return cfg, nil
+}
+
+func envInt64(key string, def int64) (int64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return n, nil
+}
+
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/models"
+	"github.com/f-chilmi/just-text-go/realtime"
+	"github.com/f-chilmi/just-text-go/responses"
+)
+
+const (
+	// interval of the keep-alive comments, proxies close idle streams
+	sseHeartbeat = 25 * time.Second
+
+	// number of messages read at once on a Last-Event-ID resumption
+	sseReplayPage = 500
+)
+
+type lastMsgRes struct {
+	IdRoom    int64     `json:"id_room"`
+	IdLastMsg int64     `json:"id_last_msg"`
+	LastMsg   string    `json:"last_msg"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StreamEvents is the server-sent events fallback of ServeWs for clients
+// that cannot upgrade to a websocket.
+func (s *Server) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		responses.ERROR(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+
+	var lastId int64
+	if h := r.Header.Get("Last-Event-ID"); h != "" {
+		lastId, err = strconv.ParseInt(h, 10, 64)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	// subscribe before replaying so nothing is lost in between
+	sub := s.hub.Subscribe(me.ID)
+	defer s.hub.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if lastId > 0 {
+		// the whole backlog is replayed, a page at a time, for the id of
+		// the client to never move past a message it did not get
+		latest := make(map[int64]models.Message)
+		for {
+			missed, err := s.messages.ListMsgSince(me.ID, lastId, sseReplayPage)
+			if err != nil {
+				log.Printf("sse: unable to replay events for user %d. %v", me.ID, err)
+				return
+			}
+			if len(missed) == 0 {
+				break
+			}
+
+			for _, msg := range missed {
+				e := realtime.Event{ID: msg.ID, Type: realtime.EventMessageNew, Data: msg}
+				if err := realtime.WriteSSE(w, e); err != nil {
+					return
+				}
+				s.eventDelivered(me.ID, e)
+				latest[msg.IdRoom] = msg
+				lastId = msg.ID
+			}
+			flusher.Flush()
+		}
+		// only the new messages carry an id, the previews come after them
+		// in any order
+		for _, msg := range latest {
+			e := realtime.Event{Type: realtime.EventRoomLastMsg, Data: lastMsgRes{
+				IdRoom:    msg.IdRoom,
+				IdLastMsg: msg.ID,
+				LastMsg:   msg.Preview(),
+				UpdatedAt: msg.CreatedAt,
+			}}
+			if err := realtime.WriteSSE(w, e); err != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			// already sent by the replay
+			if e.ID != 0 && e.ID <= lastId {
+				continue
+			}
+			if err := realtime.WriteSSE(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+			s.eventDelivered(me.ID, e)
+
+		case <-ticker.C:
+			if _, err := w.Write([]byte(": ping\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package controllers_test
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReplayPastOnePage(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+	b := signUp(t, srv, "bob", "200")
+	ta := a["token"].(string)
+
+	_, room := call(t, srv, "GET", "/phone/200", ta, nil)
+	msgPath := fmt.Sprintf("/msg/%v", field(t, room, "id"))
+
+	// more than a page of messages is missed after the first one
+	const missed = 600
+	var first, last interface{}
+	for i := 0; i <= missed; i++ {
+		status, res := call(t, srv, "POST", msgPath, ta, map[string]string{"content": fmt.Sprint(i)})
+		expectStatus(t, "send", status, http.StatusOK, res)
+		last = field(t, field(t, res, "message"), "id")
+		if i == 0 {
+			first = last
+		}
+	}
+
+	req, err := http.NewRequest("GET", srv.URL+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+b["token"].(string))
+	req.Header.Set("Last-Event-ID", fmt.Sprint(first))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	// read until the first preview, which ends the replay
+	var ids []string
+	var id string
+	scanner := bufio.NewScanner(res.Body)
+read:
+	for scanner.Scan() {
+		switch line := scanner.Text(); {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case line == "event: message.new":
+			ids = append(ids, id)
+		case line == "event: room.last_msg":
+			if id != "" {
+				t.Fatalf("expected the preview without an id, got %s", id)
+			}
+			break read
+		case line == "":
+			id = ""
+		}
+	}
+
+	if len(ids) != missed || ids[len(ids)-1] != fmt.Sprint(last) {
+		t.Fatalf("expected the %d missed messages up to %v, got %d", missed, last, len(ids))
+	}
+}
@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/models"
+	"github.com/f-chilmi/just-text-go/realtime"
+	"github.com/f-chilmi/just-text-go/responses"
+	"github.com/gorilla/mux"
+)
+
+type groupReq struct {
+	Name   string   `json:"name"`
+	Phones []string `json:"phones"`
+}
+
+type membersRes struct {
+	IdRoom  int64               `json:"id_room"`
+	Members []models.RoomMember `json:"members"`
+}
+
+func (s *Server) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req groupReq
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("required name"))
+		return
+	}
+
+	ids, err := s.userIdsByPhones(req.Phones)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	ids = append([]int64{me.ID}, ids...)
+	if len(uniqueIds(ids)) < 2 {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("a group needs at least one other member"))
+		return
+	}
+
+	idRoom, err := s.rooms.NewGroup(models.RoomDb{Name: req.Name, IdCreator: me.ID}, uniqueIds(ids))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	room, err := s.groupRoom(idRoom, me.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.hub.Publish(memberIdsOf(room.Members), realtime.Event{Type: realtime.EventRoomCreated, Data: room})
+
+	responses.JSON(w, http.StatusOK, room)
+}
+
+func (s *Server) AddMembers(w http.ResponseWriter, r *http.Request) {
+	room, err := s.groupFromParams(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	idRoom := room.ID
+
+	var req groupReq
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ids, err := s.userIdsByPhones(req.Phones)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(ids) == 0 {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("required phones"))
+		return
+	}
+
+	err = s.rooms.AddMembers(idRoom, uniqueIds(ids))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.publishMembers(w, idRoom, nil)
+}
+
+// RemoveMember lets a member leave the group, only its creator can remove
+// the other members. A creator who leaves hands the group over to the
+// member who joined first.
+func (s *Server) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	room, err := s.groupFromParams(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	idRoom := room.ID
+
+	idUser, err := strconv.ParseInt(mux.Vars(r)["idUser"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if idUser != me.ID && room.IdCreator != me.ID {
+		responses.ERROR(w, http.StatusForbidden, errors.New("only the creator of the group can remove other members"))
+		return
+	}
+
+	removed, err := s.rooms.RemoveMember(idRoom, idUser)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if removed < 1 {
+		responses.ERROR(w, http.StatusNotFound, errors.New("member not found"))
+		return
+	}
+
+	// the removed user is notified too, so its clients can drop the room
+	s.publishMembers(w, idRoom, []int64{idUser})
+}
+
+// groupFromParams finds the room of the id parameter and makes sure it is
+// a group room, members of a 1:1 room cannot change.
+func (s *Server) groupFromParams(r *http.Request) (models.RoomList, error) {
+	idRoom, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return models.RoomList{}, err
+	}
+
+	room, err := s.rooms.FindRoomById(idRoom)
+	if err != nil {
+		return models.RoomList{}, err
+	}
+	if !room.IsGroup {
+		return models.RoomList{}, errors.New("room is not a group")
+	}
+
+	return room, nil
+}
+
+// publishMembers sends the up to date member list to the members (and the
+// extra users) and as response.
+func (s *Server) publishMembers(w http.ResponseWriter, idRoom int64, extra []int64) {
+	members, err := s.rooms.ListMembers(idRoom)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res := membersRes{IdRoom: idRoom, Members: members}
+	s.hub.Publish(append(memberIdsOf(members), extra...), realtime.Event{Type: realtime.EventRoomMembers, Data: res})
+
+	responses.JSON(w, http.StatusOK, res)
+}
+
+func (s *Server) groupRoom(idRoom int64, myId int64) (models.Room, error) {
+	roomF, err := s.rooms.FindRoomById(idRoom)
+	if err != nil {
+		return models.Room{}, err
+	}
+
+	room := roomF.ToRoom(myId)
+	room.Members, err = s.rooms.ListMembers(idRoom)
+	return room, err
+}
+
+func (s *Server) userIdsByPhones(phones []string) ([]int64, error) {
+	ids := make([]int64, 0, len(phones))
+	for _, phone := range phones {
+		user, err := s.users.GetUserByPhone(strings.TrimSpace(phone))
+		switch err {
+		case sql.ErrNoRows:
+			return nil, fmt.Errorf("no user found with phone %s", phone)
+		case nil:
+			ids = append(ids, user.ID)
+		default:
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func uniqueIds(ids []int64) []int64 {
+	seen := make(map[int64]bool)
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
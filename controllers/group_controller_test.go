@@ -0,0 +1,38 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCreatorLeavingHandsOverTheGroup(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+	b := signUp(t, srv, "bob", "200")
+	c := signUp(t, srv, "carol", "300")
+	ta, tb, tc := a["token"].(string), b["token"].(string), c["token"].(string)
+
+	status, group := call(t, srv, "POST", "/room/group", ta, map[string]interface{}{"name": "friends", "phones": []string{"200", "300"}})
+	expectStatus(t, "create", status, http.StatusOK, group)
+	members := fmt.Sprintf("/room/%v/members", field(t, group, "id"))
+
+	status, res := call(t, srv, "DELETE", fmt.Sprintf("%s/%v", members, c["id"]), tb, nil)
+	expectStatus(t, "remove as a member", status, http.StatusForbidden, res)
+
+	status, res = call(t, srv, "DELETE", fmt.Sprintf("%s/%v", members, a["id"]), ta, nil)
+	expectStatus(t, "creator leaves", status, http.StatusOK, res)
+
+	// bob joined with carol and has the lowest id, the group is his
+	_, res = call(t, srv, "GET", "/room", tb, nil)
+	rooms := res.([]interface{})
+	if len(rooms) != 1 || field(t, rooms[0], "id_creator") != b["id"] {
+		t.Fatalf("expected bob as the new creator, got %v", rooms)
+	}
+
+	status, res = call(t, srv, "DELETE", fmt.Sprintf("%s/%v", members, b["id"]), tc, nil)
+	expectStatus(t, "remove the new creator", status, http.StatusForbidden, res)
+
+	status, res = call(t, srv, "DELETE", fmt.Sprintf("%s/%v", members, c["id"]), tb, nil)
+	expectStatus(t, "remove as the new creator", status, http.StatusOK, res)
+}
@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"net/http"
 
-	_ "github.com/lib/pq"
-
 	"github.com/f-chilmi/just-text-go/models"
 )
 
@@ -23,7 +21,7 @@ type responseNew struct {
 	Message models.Message `json:"message,omitempty"`
 }
 
-func HomeController(w http.ResponseWriter, r *http.Request) {
+func (s *Server) HomeController(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("Home controller called")
 	err := json.NewEncoder(w).Encode("Welcome to the awesome chat app")
 	if err != nil {
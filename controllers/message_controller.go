@@ -0,0 +1,327 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/models"
+	"github.com/f-chilmi/just-text-go/realtime"
+	"github.com/f-chilmi/just-text-go/responses"
+	"github.com/gorilla/mux"
+)
+
+type editReq struct {
+	Content string `json:"content"`
+}
+
+type deletedRes struct {
+	ID     int64  `json:"id"`
+	IdRoom int64  `json:"id_room"`
+	Scope  string `json:"scope"`
+}
+
+// checkContent validates the content of a message, it can only be empty
+// when files are attached. It returns the status to answer with otherwise.
+func (s *Server) checkContent(content string, hasAttachments bool) (int, error) {
+	if content == "" && !hasAttachments {
+		return http.StatusBadRequest, errors.New("required content")
+	}
+	if utf8.RuneCountInString(content) > s.config.MaxMessageLength {
+		return http.StatusRequestEntityTooLarge, fmt.Errorf("the message is longer than %d characters", s.config.MaxMessageLength)
+	}
+	return 0, nil
+}
+
+// room left in a message request for the json around the content, the
+// attachment ids included
+const msgBodyOverhead = 1 << 12
+
+// json can escape any character, a surrogate pair as "\ud83d\ude00" takes
+// the most bytes
+const maxEscapedRune = 12
+
+// decodeMsg decodes a message request into v. The body is only capped to
+// what the longest message can take once escaped, the length of the content
+// itself is left to checkContent. It returns the status to answer with on
+// error.
+func (s *Server) decodeMsg(w http.ResponseWriter, r *http.Request, v interface{}) (int, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(s.config.MaxMessageLength)*maxEscapedRune+msgBodyOverhead)
+	err := json.NewDecoder(r.Body).Decode(v)
+	switch {
+	case err == nil:
+		return 0, nil
+	case bodyTooLarge(err):
+		return http.StatusRequestEntityTooLarge, errors.New("the request body is too large")
+	default:
+		return http.StatusBadRequest, err
+	}
+}
+
+// bodyTooLarge tells if err comes from an http.MaxBytesReader past its
+// limit. go 1.16 has no http.MaxBytesError, the error is only known by its
+// text.
+func bodyTooLarge(err error) bool {
+	return err != nil && err.Error() == "http: request body too large"
+}
+
+// EditMsg replaces the content of a message, only its sender can do it and
+// only within the edit window.
+func (s *Server) EditMsg(w http.ResponseWriter, r *http.Request) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	idMessage, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	message, err := s.messages.GetMsg(idMessage)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if message.IdSender != me.ID {
+		responses.ERROR(w, http.StatusForbidden, errors.New("only the sender can edit a message"))
+		return
+	}
+	if message.Deleted {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("the message was deleted"))
+		return
+	}
+	within, err := s.messages.MsgSentWithin(message.ID, s.config.EditWindow)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if !within {
+		responses.ERROR(w, http.StatusForbidden, errors.New("the message can no longer be edited"))
+		return
+	}
+
+	var req editReq
+	if status, err := s.decodeMsg(w, r, &req); err != nil {
+		responses.ERROR(w, status, err)
+		return
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if status, err := s.checkContent(req.Content, false); err != nil {
+		responses.ERROR(w, status, err)
+		return
+	}
+
+	edited, err := s.messages.EditMsg(idMessage, req.Content)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	chats := []models.Message{edited}
+	err = s.withQuotes(chats)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	edited = chats[0]
+
+	members, err := s.rooms.ListMembers(edited.IdRoom)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	memberIds := memberIdsOf(members)
+	s.hub.Publish(memberIds, realtime.Event{Type: realtime.EventMessageEdited, Data: edited})
+
+	err = s.refreshLastMsg(edited.IdRoom, edited.ID, memberIds)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res := responseNew{
+		Message: edited,
+	}
+
+	responses.JSON(w, http.StatusOK, res)
+}
+
+// DeleteMsg deletes a message for the caller only (scope=me, the default)
+// or, for its sender within the delete window, for everyone
+// (scope=everyone) leaving a tombstone in the room.
+func (s *Server) DeleteMsg(w http.ResponseWriter, r *http.Request) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	idMessage, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	message, err := s.messages.GetMsg(idMessage)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res := deletedRes{ID: message.ID, IdRoom: message.IdRoom, Scope: r.URL.Query().Get("scope")}
+
+	switch res.Scope {
+	case "", "me":
+		res.Scope = "me"
+
+		err = s.messages.HideMsg(message.ID, me.ID)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+
+		// the other devices of the user drop it too
+		s.hub.Publish([]int64{me.ID}, realtime.Event{Type: realtime.EventMessageDeleted, Data: res})
+
+	case "everyone":
+		if message.IdSender != me.ID {
+			responses.ERROR(w, http.StatusForbidden, errors.New("only the sender can delete a message for everyone"))
+			return
+		}
+		if message.Deleted {
+			break
+		}
+		within, err := s.messages.MsgSentWithin(message.ID, s.config.DeleteWindow)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+		if !within {
+			responses.ERROR(w, http.StatusForbidden, errors.New("the message can no longer be deleted for everyone"))
+			return
+		}
+
+		_, err = s.messages.DeleteMsg(message.ID)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+
+		err = s.deleteMsgAttachments(message.ID)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+
+		members, err := s.rooms.ListMembers(message.IdRoom)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+		memberIds := memberIdsOf(members)
+		s.hub.Publish(memberIds, realtime.Event{Type: realtime.EventMessageDeleted, Data: res})
+
+		err = s.refreshLastMsg(message.IdRoom, message.ID, memberIds)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+
+	default:
+		responses.ERROR(w, http.StatusBadRequest, errors.New("scope must be me or everyone"))
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, res)
+}
+
+// refreshLastMsg puts the latest message back as the room preview when the
+// changed message was the latest one: edited it still is, deleted it no
+// longer is.
+func (s *Server) refreshLastMsg(idRoom int64, idChanged int64, memberIds []int64) error {
+	latest, err := s.messages.LatestMsg(idRoom)
+	switch err {
+	case nil:
+		break
+	case sql.ErrNoRows:
+		// nothing left to preview
+		latest = models.Message{IdRoom: idRoom, UpdatedAt: time.Now().UTC()}
+	default:
+		return err
+	}
+	if latest.ID > idChanged {
+		return nil
+	}
+
+	updated, err := s.rooms.UpdateLastMsg(idRoom, idChanged, latest.ID, latest.Preview())
+	if err != nil || updated == 0 {
+		// a later message was sent meanwhile, it is the preview now
+		return err
+	}
+
+	s.hub.Publish(memberIds, realtime.Event{Type: realtime.EventRoomLastMsg, Data: lastMsgRes{
+		IdRoom:    idRoom,
+		IdLastMsg: latest.ID,
+		LastMsg:   latest.Preview(),
+		UpdatedAt: latest.UpdatedAt,
+	}})
+	return nil
+}
+
+// ListMsgEdits returns the prior versions of a message, oldest first.
+func (s *Server) ListMsgEdits(w http.ResponseWriter, r *http.Request) {
+	idMessage, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	edits, err := s.messages.ListMsgEdits(idMessage)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if edits == nil {
+		edits = []models.MessageEdit{}
+	}
+
+	responses.JSON(w, http.StatusOK, edits)
+}
+
+// withQuotes fills the quoted parent of every reply.
+func (s *Server) withQuotes(chats []models.Message) error {
+	var ids []int64
+	for _, chat := range chats {
+		if chat.ReplyToId != 0 {
+			ids = append(ids, chat.ReplyToId)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	quotes, err := s.messages.ListQuotes(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range chats {
+		quote, ok := quotes[chats[i].ReplyToId]
+		if !ok {
+			continue
+		}
+		quote.Content = models.Truncate(quote.Content, models.QuoteLength)
+		chats[i].ReplyTo = &quote
+	}
+	return nil
+}
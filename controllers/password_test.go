@@ -0,0 +1,71 @@
+package controllers_test
+
+import (
+	"fmt"
+	"testing"
+)
+
+// findKey returns the path of the first key named key, at any depth.
+func findKey(v interface{}, key string, path string) (string, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if k == key {
+				return path + "." + k, true
+			}
+			if p, ok := findKey(child, key, path+"."+k); ok {
+				return p, true
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if p, ok := findKey(child, key, fmt.Sprintf("%s[%d]", path, i)); ok {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+func TestNoPasswordInResponses(t *testing.T) {
+	srv := newTestServer(t)
+
+	check := func(method string, path string, token string, body interface{}) interface{} {
+		t.Helper()
+
+		status, res := call(t, srv, method, path, token, body)
+		if status >= 400 {
+			t.Fatalf("%s %s: %d %v", method, path, status, res)
+		}
+		if p, ok := findKey(res, "password", "$"); ok {
+			t.Fatalf("%s %s: the response has a password at %s", method, path, p)
+		}
+		return res
+	}
+
+	check("POST", "/register", "", map[string]string{"username": "alice", "phone": "100", "password": "secret"})
+	check("POST", "/register", "", map[string]string{"username": "bob", "phone": "200", "password": "secret"})
+	check("POST", "/register", "", map[string]string{"username": "carol", "phone": "300", "password": "secret"})
+
+	login := check("POST", "/login", "", map[string]string{"phone": "100", "password": "secret"})
+	bob := check("POST", "/login", "", map[string]string{"phone": "200", "password": "secret"})
+	refreshed := check("POST", "/token/refresh", "", map[string]interface{}{"refresh_token": field(t, login, "refresh_token")})
+	token := field(t, refreshed, "token").(string)
+	me := field(t, login, "id")
+
+	check("GET", "/users", token, nil)
+	check("GET", "/users?q=b", token, nil)
+	check("GET", fmt.Sprintf("/user/%v", me), token, nil)
+	check("GET", fmt.Sprintf("/user/%v", field(t, bob, "id")), token, nil)
+	check("PUT", fmt.Sprintf("/user/%v", me), token, map[string]string{"username": "alicia", "phone": "100"})
+
+	room := check("GET", "/phone/200", token, nil)
+	check("POST", fmt.Sprintf("/msg/%v", field(t, room, "id")), token, map[string]string{"content": "hello bob"})
+	check("GET", "/room", token, nil)
+
+	group := check("POST", "/room/group", token, map[string]interface{}{"name": "friends", "phones": []string{"200"}})
+	check("POST", fmt.Sprintf("/room/%v/members", field(t, group, "id")), token, map[string]interface{}{"phones": []string{"300"}})
+
+	check("GET", "/search?q=hello", token, nil)
+	check("GET", "/search?q=hello", field(t, bob, "token").(string), nil)
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/models"
+	"github.com/f-chilmi/just-text-go/realtime"
+	"github.com/f-chilmi/just-text-go/responses"
+	"github.com/gorilla/mux"
+)
+
+// longest emoji accepted, in characters, sequences joined with ZWJ or
+// modifiers take several
+const maxEmojiLength = 16
+
+type reactionReq struct {
+	Emoji string `json:"emoji"`
+}
+
+type reactionsRes struct {
+	ID        int64             `json:"id"`
+	IdRoom    int64             `json:"id_room"`
+	Reactions []models.Reaction `json:"reactions"`
+}
+
+// reactionEvent tells the members who added or removed which emoji, each
+// client knows whether it is itself.
+type reactionEvent struct {
+	ID     int64  `json:"id"`
+	IdRoom int64  `json:"id_room"`
+	IdUser int64  `json:"id_user"`
+	Emoji  string `json:"emoji"`
+	Added  bool   `json:"added"`
+}
+
+func (s *Server) AddReaction(w http.ResponseWriter, r *http.Request) {
+	var req reactionReq
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.react(w, r, req.Emoji, true)
+}
+
+func (s *Server) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	s.react(w, r, mux.Vars(r)["emoji"], false)
+}
+
+// react adds or removes the reaction of the user to the message in the {id}
+// route variable, then answers with the up to date reactions.
+func (s *Server) react(w http.ResponseWriter, r *http.Request, emoji string, add bool) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	idMessage, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	emoji = strings.TrimSpace(emoji)
+	if err := validateEmoji(emoji); err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	message, err := s.messages.GetMsg(idMessage)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	changed := true
+	if add {
+		if message.Deleted {
+			responses.ERROR(w, http.StatusBadRequest, errors.New("the message was deleted"))
+			return
+		}
+		err = s.messages.AddReaction(message.ID, me.ID, emoji)
+	} else {
+		var removed int64
+		removed, err = s.messages.RemoveReaction(message.ID, me.ID, emoji)
+		changed = removed > 0
+	}
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if changed {
+		members, err := s.rooms.ListMembers(message.IdRoom)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+		s.hub.Publish(memberIdsOf(members), realtime.Event{Type: realtime.EventMessageReaction, Data: reactionEvent{
+			ID:     message.ID,
+			IdRoom: message.IdRoom,
+			IdUser: me.ID,
+			Emoji:  emoji,
+			Added:  add,
+		}})
+	}
+
+	reactions, err := s.messages.ListReactions([]int64{message.ID}, me.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res := reactionsRes{ID: message.ID, IdRoom: message.IdRoom, Reactions: reactions[message.ID]}
+	if res.Reactions == nil {
+		res.Reactions = []models.Reaction{}
+	}
+
+	responses.JSON(w, http.StatusOK, res)
+}
+
+// validateEmoji only accepts a short run of symbols, letters and spaces
+// are not reactions.
+func validateEmoji(emoji string) error {
+	if emoji == "" {
+		return errors.New("required emoji")
+	}
+	if utf8.RuneCountInString(emoji) > maxEmojiLength {
+		return errors.New("emoji is too long")
+	}
+	for _, c := range emoji {
+		if c < utf8.RuneSelf || unicode.IsLetter(c) || unicode.IsSpace(c) || unicode.IsDigit(c) {
+			return errors.New("invalid emoji")
+		}
+	}
+	return nil
+}
+
+// withReactions fills the reactions of every message, as seen by idUser.
+func (s *Server) withReactions(chats []models.Message, idUser int64) error {
+	if len(chats) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(chats))
+	for _, chat := range chats {
+		ids = append(ids, chat.ID)
+	}
+
+	reactions, err := s.messages.ListReactions(ids, idUser)
+	if err != nil {
+		return err
+	}
+
+	for i := range chats {
+		chats[i].Reactions = reactions[chats[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/models"
+	"github.com/f-chilmi/just-text-go/realtime"
+	"github.com/f-chilmi/just-text-go/responses"
+	"github.com/gorilla/mux"
+)
+
+type readReq struct {
+	MessageId int64 `json:"message_id"`
+}
+
+// MarkRead moves the read cursor of the user in the room, up to the given
+// message or to the latest one when the body is empty.
+func (s *Server) MarkRead(w http.ResponseWriter, r *http.Request) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	idRoom, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req readReq
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_, err = s.rooms.MarkRead(idRoom, me.ID, req.MessageId)
+	switch err {
+	case nil:
+		break
+	case sql.ErrNoRows:
+		responses.ERROR(w, http.StatusNotFound, errors.New("room not found"))
+		return
+	default:
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// senders learn their messages were read
+	res, err := s.publishCursor(idRoom, me.ID, realtime.EventRoomRead)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, res)
+}
+
+// MessageStatus details the status of a message for each of its
+// recipients, the other members of its room.
+func (s *Server) MessageStatus(w http.ResponseWriter, r *http.Request) {
+	idMessage, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	message, err := s.messages.GetMsg(idMessage)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	cursors, err := s.rooms.ListReadCursors(message.IdRoom)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	status, recipients := recipientStatuses(message, cursors)
+	res := models.MessageStatus{
+		IdMessage:  message.ID,
+		IdRoom:     message.IdRoom,
+		Status:     status,
+		Recipients: recipients,
+	}
+
+	responses.JSON(w, http.StatusOK, res)
+}
+
+// eventDelivered marks the message of a message.new event pushed to the
+// user as delivered.
+func (s *Server) eventDelivered(idUser int64, e realtime.Event) {
+	if e.Type != realtime.EventMessageNew {
+		return
+	}
+	msg, ok := e.Data.(models.Message)
+	if !ok || msg.IdSender == idUser {
+		return
+	}
+	s.markDelivered(msg.IdRoom, idUser, msg.ID)
+}
+
+// markDelivered moves the delivery cursor of the user and lets the members
+// know when it did. Failures are only logged, the message itself got to
+// the user anyway.
+func (s *Server) markDelivered(idRoom int64, idUser int64, idMessage int64) {
+	moved, err := s.rooms.MarkDelivered(idRoom, idUser, idMessage)
+	if err != nil {
+		log.Printf("unable to mark message %d delivered to user %d. %v", idMessage, idUser, err)
+		return
+	}
+	if !moved {
+		return
+	}
+
+	if _, err := s.publishCursor(idRoom, idUser, realtime.EventRoomDelivered); err != nil {
+		log.Printf("unable to publish the delivery of message %d to user %d. %v", idMessage, idUser, err)
+	}
+}
+
+// publishCursor sends the cursors of the user to every member of the room
+// and returns them.
+func (s *Server) publishCursor(idRoom int64, idUser int64, eventType string) (models.ReadCursor, error) {
+	cursors, err := s.rooms.ListReadCursors(idRoom)
+	if err != nil {
+		return models.ReadCursor{}, err
+	}
+
+	var mine models.ReadCursor
+	memberIds := make([]int64, 0, len(cursors))
+	for _, c := range cursors {
+		if c.IdUser == idUser {
+			mine = c
+		}
+		memberIds = append(memberIds, c.IdUser)
+	}
+
+	s.hub.Publish(memberIds, realtime.Event{Type: eventType, Data: mine})
+
+	return mine, nil
+}
+
+// withReceipts fills the status of each message and the members who have
+// read it.
+func withReceipts(chats []models.Message, cursors []models.ReadCursor) {
+	for i := range chats {
+		status, recipients := recipientStatuses(chats[i], cursors)
+		chats[i].Status = status
+		for _, rs := range recipients {
+			if rs.Status == models.MessageRead {
+				chats[i].ReadBy = append(chats[i].ReadBy, rs.IdUser)
+			}
+		}
+	}
+}
+
+// recipientStatuses returns the status of the message for every member but
+// its sender, and the lowest of them (sent when there is nobody else).
+func recipientStatuses(message models.Message, cursors []models.ReadCursor) (string, []models.RecipientStatus) {
+	lowest := models.MessageSent
+	recipients := []models.RecipientStatus{}
+
+	for _, c := range cursors {
+		if c.IdUser == message.IdSender {
+			continue
+		}
+		status := c.StatusOf(message.ID)
+		if len(recipients) == 0 || statusRank[status] < statusRank[lowest] {
+			lowest = status
+		}
+		recipients = append(recipients, models.RecipientStatus{IdUser: c.IdUser, Status: status})
+	}
+
+	return lowest, recipients
+}
+
+// order of the statuses in the lifecycle
+var statusRank = map[string]int{
+	models.MessageSent:      0,
+	models.MessageDelivered: 1,
+	models.MessageRead:      2,
+}
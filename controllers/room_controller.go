@@ -2,20 +2,32 @@ package controllers
 
 import (
 	"database/sql"
-	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/f-chilmi/just-text-go/auth"
 	"github.com/f-chilmi/just-text-go/models"
+	"github.com/f-chilmi/just-text-go/realtime"
 	"github.com/f-chilmi/just-text-go/responses"
 	"github.com/gorilla/mux"
 )
 
-func SendMsg(w http.ResponseWriter, r *http.Request) {
-	messageM := models.Message{}
-	roomM := models.Room{}
+type sendMsgReq struct {
+	Content       string  `json:"content"`
+	ReplyToId     int64   `json:"reply_to_id"`
+	AttachmentIds []int64 `json:"attachment_ids"`
+}
+
+const (
+	// page size of a room history when no limit is given, and its maximum
+	defaultMsgLimit = 50
+	maxMsgLimit     = 100
+)
 
+func (s *Server) SendMsg(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	idRoom, err := (strconv.Atoi(params["id"]))
 	if err != nil {
@@ -23,43 +35,98 @@ func SendMsg(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	myId, err := auth.ExtracTokenID(r)
+	me, err := auth.CurrentUser(r)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
 	// check if rooms existed
-	_, err = roomM.FindRoomById(int64(idRoom))
+	room, err := s.rooms.FindRoomById(int64(idRoom))
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	var message models.Message
-	err = json.NewDecoder(r.Body).Decode(&message)
+	members, err := s.rooms.ListMembers(room.ID)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
-	message.IdSender = myId
-	message.IdRoom = int64(idRoom)
 
-	newM, err := messageM.NewMsg(message)
+	var req sendMsgReq
+	if status, err := s.decodeMsg(w, r, &req); err != nil {
+		responses.ERROR(w, status, err)
+		return
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if status, err := s.checkContent(req.Content, len(req.AttachmentIds) > 0); err != nil {
+		responses.ERROR(w, status, err)
+		return
+	}
+
+	// a reply quotes a message of the same room
+	if req.ReplyToId != 0 {
+		parent, err := s.messages.GetMsg(req.ReplyToId)
+		if err != nil || parent.IdRoom != room.ID {
+			responses.ERROR(w, http.StatusBadRequest, errors.New("reply_to_id must be a message of the room"))
+			return
+		}
+	}
+
+	err = s.checkAttachments(req.AttachmentIds, room.ID, me.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	message := models.Message{
+		IdSender:  me.ID,
+		IdRoom:    int64(idRoom),
+		Content:   req.Content,
+		ReplyToId: req.ReplyToId,
+	}
+	// a 1:1 message has the other user as recipient, a group message fans out to every member
+	if !room.IsGroup {
+		message.IdRecipient = room.ToRoom(me.ID).IdRecipient
+	}
+
+	// the room preview and the cursors of the sender are updated along
+	message, err = s.messages.SendMsg(message, req.AttachmentIds)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
+	message.Status = models.MessageSent
 
-	message.ID = newM.ID
-	message.CreatedAt = newM.CreatedAt
-	message.UpdatedAt = newM.UpdatedAt
+	sent := []models.Message{message}
+	err = s.withQuotes(sent)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
 
-	err = roomM.UpdateLastMsg(int64(idRoom), message.Content)
+	err = s.withAttachments(sent)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
+	message = sent[0]
+
+	// push the new message and room preview to every connected member of the room
+	memberIds := memberIdsOf(members)
+	s.hub.Publish(memberIds, realtime.Event{ID: message.ID, Type: realtime.EventMessageNew, Data: message})
+	s.hub.Publish(memberIds, realtime.Event{Type: realtime.EventRoomLastMsg, Data: lastMsgRes{
+		IdRoom:    message.IdRoom,
+		IdLastMsg: message.ID,
+		LastMsg:   message.Preview(),
+		UpdatedAt: message.CreatedAt,
+	}})
+
+	// sending reads the room, the earlier messages of the others are read now
+	if _, err := s.publishCursor(message.IdRoom, me.ID, realtime.EventRoomRead); err != nil {
+		log.Printf("unable to publish the read cursor of user %d. %v", me.ID, err)
+	}
 
 	res := responseNew{
 		Message: message,
@@ -69,14 +136,11 @@ func SendMsg(w http.ResponseWriter, r *http.Request) {
 	responses.JSON(w, http.StatusOK, res)
 }
 
-func FindRoomByPhone(w http.ResponseWriter, r *http.Request) {
-	roomM := models.Room{}
-	userM := models.User{}
-
+func (s *Server) FindRoomByPhone(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	phone := params["phone"]
 
-	user, err := userM.GetUserByPhone(phone)
+	user, err := s.users.GetUserByPhone(phone)
 	switch err {
 	case sql.ErrNoRows:
 		res := basicRes{Message: "no user found"}
@@ -89,49 +153,41 @@ func FindRoomByPhone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	myId, err := auth.ExtracTokenID(r)
+	me, err := auth.CurrentUser(r)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
 	// if user found, then check is there room for id token (me) and user.ID
-	roomExisted, err := roomM.FindRoom(int64(myId), int64(user.ID))
+	roomExisted, err := s.rooms.FindRoom(me.ID, int64(user.ID))
 	switch err {
 	case sql.ErrNoRows:
 
 		newR := models.RoomDb{
-			IdUser1: myId,
+			IdUser1: me.ID,
 			IdUser2: user.ID,
 			LastMsg: "",
 		}
 
-		idRoom, err := roomM.NewRoom(newR)
+		idRoom, err := s.rooms.NewRoom(newR)
 		if err != nil {
 			responses.ERROR(w, http.StatusBadRequest, err)
 			return
 		}
 
 		var roomF models.RoomList
-		roomF, err = roomM.FindRoomById(idRoom)
+		roomF, err = s.rooms.FindRoomById(idRoom)
 		if err != nil {
 			responses.ERROR(w, http.StatusBadRequest, err)
 			return
 		}
 
-		roomExisted.ID = roomF.ID
-		roomExisted.LastMsg = roomF.LastMsg
-		roomExisted.CreatedAt = roomF.CreatedAt
-		roomExisted.UpdatedAt = roomF.UpdatedAt
-		if roomF.IdUser1 == myId {
-			roomExisted.IdRecipient = roomF.IdUser2
-			roomExisted.UnameRecipient = roomF.Username2
-			roomExisted.PhoneRecipient = roomF.Phone2
-		} else {
-			roomExisted.IdRecipient = roomF.IdUser1
-			roomExisted.UnameRecipient = roomF.Username1
-			roomExisted.PhoneRecipient = roomF.Phone1
-		}
+		roomExisted = roomF.ToRoom(me.ID)
+
+		// each side gets the room from its own point of view
+		s.hub.Publish([]int64{me.ID}, realtime.Event{Type: realtime.EventRoomCreated, Data: roomExisted})
+		s.hub.Publish([]int64{user.ID}, realtime.Event{Type: realtime.EventRoomCreated, Data: roomF.ToRoom(user.ID)})
 
 	case nil:
 		break
@@ -145,41 +201,126 @@ func FindRoomByPhone(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func OpenRoom(w http.ResponseWriter, r *http.Request) {
+func (s *Server) OpenRoom(w http.ResponseWriter, r *http.Request) {
 	var err error
 
-	roomM := models.Room{}
 	params := mux.Vars(r)
 
-	idR, err := strconv.Atoi(params["id"])
+	idR, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	before, err := queryInt(r, "before", 0)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	after, err := queryInt(r, "after", 0)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if before > 0 && after > 0 {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("use either before or after, not both"))
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultMsgLimit)
+	if err != nil || limit < 1 {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("limit must be a positive number"))
+		return
+	}
+	if limit > maxMsgLimit {
+		limit = maxMsgLimit
+	}
+
+	// one more message tells whether there is a next page
+	roomChat, err := s.messages.OpenRoomChat(idR, me.ID, before, after, int(limit)+1)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	roomChat, err := roomM.OpenRoomChat(idR)
+	page := models.MessagePage{Data: roomChat}
+	if len(roomChat) > int(limit) {
+		var next int64
+		if after > 0 {
+			page.Data = roomChat[:limit]
+			next = page.Data[len(page.Data)-1].ID
+		} else {
+			page.Data = roomChat[1:]
+			next = page.Data[0].ID
+		}
+		page.NextCursor = &next
+	}
+	if page.Data == nil {
+		page.Data = []models.Message{}
+	}
+
+	// fetching the history delivers it to this user
+	if n := len(page.Data); n > 0 {
+		s.markDelivered(idR, me.ID, page.Data[n-1].ID)
+	}
 
+	cursors, err := s.rooms.ListReadCursors(idR)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
+	withReceipts(page.Data, cursors)
 
-	responses.JSON(w, http.StatusOK, roomChat)
+	err = s.withQuotes(page.Data)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = s.withReactions(page.Data, me.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = s.withAttachments(page.Data)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, page)
 }
 
-func ListRoom(w http.ResponseWriter, r *http.Request) {
+// queryInt parses the query parameter name, def when it is missing.
+func queryInt(r *http.Request, name string, def int64) (int64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
 
-	var err error
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid " + name + " parameter")
+	}
+	return n, nil
+}
 
-	roomM := models.Room{}
+func (s *Server) ListRoom(w http.ResponseWriter, r *http.Request) {
+	var err error
 
-	myId, err := auth.ExtracTokenID(r)
+	me, err := auth.CurrentUser(r)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	roomChat, err := roomM.ListRoomByToken(int(myId))
+	roomChat, err := s.rooms.ListRoomByToken(me.ID)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
@@ -189,3 +330,11 @@ func ListRoom(w http.ResponseWriter, r *http.Request) {
 	responses.JSON(w, http.StatusOK, roomChat)
 
 }
+
+func memberIdsOf(members []models.RoomMember) []int64 {
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
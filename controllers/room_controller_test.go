@@ -0,0 +1,170 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFindRoomByPhone(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+	b := signUp(t, srv, "bob", "200")
+	ta, tb := a["token"].(string), b["token"].(string)
+
+	status, res := call(t, srv, "GET", "/phone/200", ta, nil)
+	expectStatus(t, "first lookup", status, http.StatusOK, res)
+	idRoom := field(t, res, "id")
+	if got := field(t, res, "id_recipient"); got != b["id"] {
+		t.Fatalf("expected bob as recipient, got %v", got)
+	}
+
+	// the same room is found again, from both sides
+	status, res = call(t, srv, "GET", "/phone/200", ta, nil)
+	expectStatus(t, "second lookup", status, http.StatusOK, res)
+	if got := field(t, res, "id"); got != idRoom {
+		t.Fatalf("expected room %v again, got %v", idRoom, got)
+	}
+
+	status, res = call(t, srv, "GET", "/phone/100", tb, nil)
+	expectStatus(t, "lookup from bob", status, http.StatusOK, res)
+	if got := field(t, res, "id"); got != idRoom {
+		t.Fatalf("expected bob to find room %v, got %v", idRoom, got)
+	}
+	if got := field(t, res, "id_recipient"); got != a["id"] {
+		t.Fatalf("expected alice as recipient, got %v", got)
+	}
+
+	status, res = call(t, srv, "GET", "/phone/999", ta, nil)
+	expectStatus(t, "unknown phone", status, http.StatusBadRequest, res)
+
+	status, res = call(t, srv, "GET", "/phone/200", "", nil)
+	expectStatus(t, "without token", status, http.StatusUnauthorized, res)
+}
+
+func TestSendMsg(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+	b := signUp(t, srv, "bob", "200")
+	c := signUp(t, srv, "carol", "300")
+	ta, tb, tc := a["token"].(string), b["token"].(string), c["token"].(string)
+
+	_, room := call(t, srv, "GET", "/phone/200", ta, nil)
+	msgPath := fmt.Sprintf("/msg/%v", field(t, room, "id"))
+
+	status, res := call(t, srv, "POST", msgPath, ta, map[string]string{"content": "  hello bob  "})
+	expectStatus(t, "send", status, http.StatusOK, res)
+	message := field(t, res, "message")
+	if got := field(t, message, "content"); got != "hello bob" {
+		t.Fatalf("expected the trimmed content, got %q", got)
+	}
+	if got := field(t, message, "id_recipient"); got != b["id"] {
+		t.Fatalf("expected bob as recipient, got %v", got)
+	}
+	if got := field(t, message, "status"); got != "sent" {
+		t.Fatalf("expected status sent, got %v", got)
+	}
+
+	// the recipient sees it in the room and as its preview
+	status, res = call(t, srv, "GET", fmt.Sprintf("/room/%v", field(t, room, "id")), tb, nil)
+	expectStatus(t, "open room", status, http.StatusOK, res)
+	data := field(t, res, "data").([]interface{})
+	if len(data) != 1 || field(t, data[0], "content") != "hello bob" {
+		t.Fatalf("expected the sent message, got %v", data)
+	}
+
+	status, res = call(t, srv, "GET", "/room", tb, nil)
+	expectStatus(t, "list rooms", status, http.StatusOK, res)
+	rooms := res.([]interface{})
+	if len(rooms) != 1 || field(t, rooms[0], "last_msg") != "hello bob" || field(t, rooms[0], "unread_count") != float64(1) {
+		t.Fatalf("expected the room with its preview and one unread message, got %v", rooms)
+	}
+
+	status, res = call(t, srv, "POST", msgPath, tc, map[string]string{"content": "intruder"})
+	expectStatus(t, "send as a non member", status, http.StatusForbidden, res)
+
+	status, res = call(t, srv, "POST", "/msg/999", ta, map[string]string{"content": "nowhere"})
+	expectStatus(t, "send to an unknown room", status, http.StatusNotFound, res)
+
+	status, res = call(t, srv, "POST", msgPath, ta, map[string]string{"content": "   "})
+	expectStatus(t, "send empty content", status, http.StatusBadRequest, res)
+
+	status, res = call(t, srv, "POST", msgPath, ta, map[string]interface{}{"content": "re", "reply_to_id": 999})
+	expectStatus(t, "reply to an unknown message", status, http.StatusBadRequest, res)
+}
+
+func TestUnreadCountSkipsDeletedMessages(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+	b := signUp(t, srv, "bob", "200")
+	ta, tb := a["token"].(string), b["token"].(string)
+
+	_, room := call(t, srv, "GET", "/phone/200", ta, nil)
+	msgPath := fmt.Sprintf("/msg/%v", field(t, room, "id"))
+
+	var ids []interface{}
+	for _, content := range []string{"one", "two", "three"} {
+		status, res := call(t, srv, "POST", msgPath, ta, map[string]string{"content": content})
+		expectStatus(t, "send", status, http.StatusOK, res)
+		ids = append(ids, field(t, field(t, res, "message"), "id"))
+	}
+
+	status, res := call(t, srv, "DELETE", fmt.Sprintf("/msg/%v?scope=everyone", ids[0]), ta, nil)
+	expectStatus(t, "delete for everyone", status, http.StatusOK, res)
+	status, res = call(t, srv, "DELETE", fmt.Sprintf("/msg/%v?scope=me", ids[1]), tb, nil)
+	expectStatus(t, "delete for me", status, http.StatusOK, res)
+
+	_, res = call(t, srv, "GET", "/room", tb, nil)
+	rooms := res.([]interface{})
+	if len(rooms) != 1 || field(t, rooms[0], "unread_count") != float64(1) {
+		t.Fatalf("expected only the last message to be unread, got %v", rooms)
+	}
+}
+
+func TestMessageBodyIsCapped(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+	signUp(t, srv, "bob", "200")
+	ta := a["token"].(string)
+
+	_, room := call(t, srv, "GET", "/phone/200", ta, nil)
+	msgPath := fmt.Sprintf("/msg/%v", field(t, room, "id"))
+
+	status, res := call(t, srv, "POST", msgPath, ta, map[string]string{"content": "hello"})
+	expectStatus(t, "send", status, http.StatusOK, res)
+	editPath := fmt.Sprintf("/msg/%v", field(t, field(t, res, "message"), "id"))
+
+	// far past what the longest message takes, whitespace included
+	huge := strings.Repeat(" ", 1<<20)
+	status, res = call(t, srv, "POST", msgPath, ta, map[string]string{"content": huge})
+	expectStatus(t, "send a huge body", status, http.StatusRequestEntityTooLarge, res)
+	status, res = call(t, srv, "PATCH", editPath, ta, map[string]string{"content": huge})
+	expectStatus(t, "edit with a huge body", status, http.StatusRequestEntityTooLarge, res)
+}
+
+func TestEscapedMessageAtTheLimit(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+	signUp(t, srv, "bob", "200")
+	ta := a["token"].(string)
+
+	_, room := call(t, srv, "GET", "/phone/200", ta, nil)
+	msgPath := fmt.Sprintf("/msg/%v", field(t, room, "id"))
+
+	// every character escaped as a surrogate pair, the longest json allows
+	escaped := func(n int) json.RawMessage {
+		return json.RawMessage(`{"content":"` + strings.Repeat(`\ud83d\ude00`, n) + `"}`)
+	}
+
+	status, res := call(t, srv, "POST", msgPath, ta, escaped(maxMessageLength))
+	expectStatus(t, "send at the limit", status, http.StatusOK, res)
+	editPath := fmt.Sprintf("/msg/%v", field(t, field(t, res, "message"), "id"))
+
+	status, res = call(t, srv, "PATCH", editPath, ta, escaped(maxMessageLength))
+	expectStatus(t, "edit at the limit", status, http.StatusOK, res)
+
+	status, res = call(t, srv, "POST", msgPath, ta, escaped(maxMessageLength+1))
+	expectStatus(t, "send past the limit", status, http.StatusRequestEntityTooLarge, res)
+}
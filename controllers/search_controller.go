@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/models"
+	"github.com/f-chilmi/just-text-go/responses"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// longest search query, in characters
+	maxSearchLength = 200
+)
+
+// Search finds the messages matching q in the rooms of the caller, newest
+// first. A page is continued with its next_cursor as before.
+func (s *Server) Search(w http.ResponseWriter, r *http.Request) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("required q"))
+		return
+	}
+	if utf8.RuneCountInString(q) > maxSearchLength {
+		responses.ERROR(w, http.StatusBadRequest, fmt.Errorf("q is longer than %d characters", maxSearchLength))
+		return
+	}
+
+	before, err := queryInt(r, "before", 0)
+	if err != nil || before < 0 {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("before must be a message id"))
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultSearchLimit)
+	if err != nil || limit < 1 {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("limit must be a positive number"))
+		return
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	// one more hit tells whether there is a next page
+	hits, err := s.messages.SearchMsg(me.ID, q, before, int(limit)+1)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page := models.SearchPage{Data: []models.SearchHit{}}
+	if len(hits) > int(limit) {
+		hits = hits[:limit]
+		next := hits[len(hits)-1].IdMessage
+		page.NextCursor = &next
+	}
+	page.Data = append(page.Data, hits...)
+
+	responses.JSON(w, http.StatusOK, page)
+}
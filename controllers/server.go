@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/realtime"
+	"github.com/f-chilmi/just-text-go/repository"
+	"github.com/f-chilmi/just-text-go/storage"
+	"github.com/f-chilmi/just-text-go/thumbnails"
+)
+
+// Server holds the dependencies shared by every handler.
+type Server struct {
+	users       repository.UserRepository
+	rooms       repository.RoomRepository
+	messages    repository.MessageRepository
+	attachments repository.AttachmentRepository
+	tokens      repository.TokenRepository
+	auth        *auth.Authenticator
+	hub         *realtime.Hub
+	blobs       storage.BlobStore
+	thumbs      *thumbnails.Worker
+	config      Config
+}
+
+func NewServer(store *repository.Store, authenticator *auth.Authenticator, hub *realtime.Hub, blobs storage.BlobStore, thumbs *thumbnails.Worker, config Config) *Server {
+	return &Server{
+		users:       store.Users,
+		rooms:       store.Rooms,
+		messages:    store.Messages,
+		attachments: store.Attachments,
+		tokens:      store.Tokens,
+		auth:        authenticator,
+		hub:         hub,
+		blobs:       blobs,
+		thumbs:      thumbs,
+		config:      config,
+	}
+}
@@ -0,0 +1,122 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/controllers"
+	"github.com/f-chilmi/just-text-go/middlewares"
+	"github.com/f-chilmi/just-text-go/realtime"
+	"github.com/f-chilmi/just-text-go/repository"
+	"github.com/f-chilmi/just-text-go/router"
+	"github.com/f-chilmi/just-text-go/storage"
+	"github.com/f-chilmi/just-text-go/thumbnails"
+)
+
+// long enough for the json overhead not to hide the cap on message bodies
+const maxMessageLength = 1000
+
+// newTestServer serves the whole api on the in-memory store.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	store := repository.NewMemoryStore()
+	keys, err := auth.NewKeySet("", auth.NewHMACKey("default", []byte("test-secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := auth.NewAuthenticator(keys, store.Tokens)
+
+	blobs, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := controllers.NewServer(store, authenticator, realtime.NewHub(), blobs, thumbnails.NewWorker(store.Attachments, blobs), controllers.Config{
+		EditWindow:        time.Minute,
+		DeleteWindow:      time.Minute,
+		MaxAttachmentSize: 1 << 20,
+		MaxMessageLength:  maxMessageLength,
+	})
+
+	srv := httptest.NewServer(router.Router(server, middlewares.New(authenticator, store)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// call sends body as json and returns the status with the decoded response.
+func call(t *testing.T, srv *httptest.Server, method string, path string, token string, body interface{}) (int, interface{}) {
+	t.Helper()
+
+	var payload io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, srv.URL+path, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var decoded interface{}
+	if err := json.NewDecoder(res.Body).Decode(&decoded); err != nil && err != io.EOF {
+		t.Fatalf("%s %s: invalid json. %v", method, path, err)
+	}
+	return res.StatusCode, decoded
+}
+
+// signUp registers a user and returns its login response.
+func signUp(t *testing.T, srv *httptest.Server, username string, phone string) map[string]interface{} {
+	t.Helper()
+
+	credentials := map[string]string{"username": username, "phone": phone, "password": "secret"}
+	if status, res := call(t, srv, "POST", "/register", "", credentials); status != http.StatusOK {
+		t.Fatalf("register %s: %d %v", phone, status, res)
+	}
+
+	status, res := call(t, srv, "POST", "/login", "", credentials)
+	if status != http.StatusOK {
+		t.Fatalf("login %s: %d %v", phone, status, res)
+	}
+	if field(t, res, "id") == nil || field(t, res, "token") == nil {
+		t.Fatalf("login %s: expected the id and the token, got %v", phone, res)
+	}
+	return res.(map[string]interface{})
+}
+
+func field(t *testing.T, v interface{}, key string) interface{} {
+	t.Helper()
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected an object with %q, got %v", key, v)
+	}
+	return m[key]
+}
+
+func expectStatus(t *testing.T, what string, got int, want int, res interface{}) {
+	t.Helper()
+
+	if got != want {
+		t.Fatalf("%s: expected %d, got %d %v", what, want, got, res)
+	}
+}
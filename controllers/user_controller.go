@@ -2,31 +2,79 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
-	"github.com/f-chilmi/just-text-go/helpers"
+	"github.com/f-chilmi/just-text-go/auth"
 	"github.com/f-chilmi/just-text-go/models"
 	"github.com/f-chilmi/just-text-go/responses"
 	"github.com/gorilla/mux"
 )
 
-func FindAll(w http.ResponseWriter, r *http.Request) {
-	userM := models.User{}
+const (
+	// page size of the user directory when no limit is given, and its maximum
+	defaultUserLimit = 20
+	maxUserLimit     = 100
+)
+
+func (s *Server) FindAll(w http.ResponseWriter, r *http.Request) {
+	query := models.UserQuery{Q: strings.TrimSpace(r.URL.Query().Get("q"))}
 
-	users, err := userM.GetUsers()
+	// sort is username or created_at, a leading - reverses it
+	query.Sort = r.URL.Query().Get("sort")
+	if strings.HasPrefix(query.Sort, "-") {
+		query.Sort = query.Sort[1:]
+		query.Desc = true
+	}
+	switch query.Sort {
+	case "":
+		query.Sort = "username"
+	case "username", "created_at":
+	default:
+		responses.ERROR(w, http.StatusBadRequest, errors.New("sort must be username or created_at"))
+		return
+	}
 
+	limit, err := queryInt(r, "limit", defaultUserLimit)
+	if err != nil || limit < 1 {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("limit must be a positive number"))
+		return
+	}
+	if limit > maxUserLimit {
+		limit = maxUserLimit
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("offset must not be negative"))
+		return
+	}
+	query.Limit, query.Offset = int(limit)+1, int(offset)
+
+	// one more user tells whether there is a next page
+	users, err := s.users.GetUsers(query)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	// send all the users as response
-	responses.JSON(w, http.StatusOK, users)
+	page := models.UserPage{Data: []models.PublicUser{}}
+	if len(users) > int(limit) {
+		users = users[:limit]
+		next := query.Offset + len(users)
+		page.NextOffset = &next
+	}
+	for _, user := range users {
+		page.Data = append(page.Data, user.Public())
+	}
+
+	// send the page of users as response
+	responses.JSON(w, http.StatusOK, page)
 }
 
-func FindById(w http.ResponseWriter, r *http.Request) {
+func (s *Server) FindById(w http.ResponseWriter, r *http.Request) {
 	// get the userid from the request params, key is "id"
 	params := mux.Vars(r)
 
@@ -37,39 +85,50 @@ func FindById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userM := models.User{}
-
-	user, err := userM.GetUser(int64(id))
+	user, err := s.users.GetUser(int64(id))
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	// send all the users as response
-	responses.JSON(w, http.StatusOK, user)
+	// users see a bit more of their own account
+	me, err := auth.CurrentUser(r)
+	if err == nil && me.ID == user.ID {
+		responses.JSON(w, http.StatusOK, user.Self())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, user.Public())
 }
 
-func UpdateUser(w http.ResponseWriter, r *http.Request) {
+func (s *Server) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	// get the userid from the request params, key is "id"
 	params := mux.Vars(r)
 
 	// convert the id type from string to int
 	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
 
-	helpers.CheckError("Unable to convert the string into int", err)
-
-	// create an empty user of type models.User
-	var user models.User
+	// only the username and the phone can be updated here
+	var data models.UserData
 
 	// decode the json request to user
-	err = json.NewDecoder(r.Body).Decode(&user)
-
-	helpers.CheckError("Unable to decode the request body", err)
+	err = json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
 
 	// call update user to update the user
-	userM := models.User{}
-
-	updatedRows := userM.UpdateUser(int64(id), user)
+	user := models.User{Username: data.Username, Phone: data.Phone}
+	updatedRows, err := s.users.UpdateUser(int64(id), user)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
 
 	// format the message string
 	msg := fmt.Sprintf("Total rows/record affected %v", updatedRows)
@@ -0,0 +1,30 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestPhoneIsUnique(t *testing.T) {
+	srv := newTestServer(t)
+	a := signUp(t, srv, "alice", "100")
+	b := signUp(t, srv, "bob", "200")
+
+	status, res := call(t, srv, "POST", "/register", "", map[string]string{"username": "eve", "phone": "200", "password": "secret"})
+	expectStatus(t, "register a taken phone", status, http.StatusBadRequest, res)
+
+	path := fmt.Sprintf("/user/%v", a["id"])
+	status, res = call(t, srv, "PUT", path, a["token"].(string), map[string]string{"username": "alice", "phone": "200"})
+	expectStatus(t, "update to a taken phone", status, http.StatusBadRequest, res)
+
+	// keeping one's own phone is not a conflict
+	status, res = call(t, srv, "PUT", path, a["token"].(string), map[string]string{"username": "alicia", "phone": "100"})
+	expectStatus(t, "update keeping the phone", status, http.StatusOK, res)
+
+	status, res = call(t, srv, "GET", "/phone/200", a["token"].(string), nil)
+	expectStatus(t, "lookup", status, http.StatusOK, res)
+	if got := field(t, res, "id_recipient"); got != b["id"] {
+		t.Fatalf("expected the phone to still be bob's, got %v", got)
+	}
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/realtime"
+	"github.com/f-chilmi/just-text-go/responses"
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// the api is served with Access-Control-Allow-Origin "*" and requires a token anyway
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func (s *Server) ServeWs(w http.ResponseWriter, r *http.Request) {
+	me, err := auth.CurrentUser(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied with an http error
+		log.Println(err)
+		return
+	}
+
+	realtime.ServeClient(s.hub, conn, me.ID, func(e realtime.Event) {
+		s.eventDelivered(me.ID, e)
+	})
+}
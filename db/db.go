@@ -4,39 +4,98 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
-
-	"github.com/f-chilmi/just-text-go/helpers"
-	"github.com/joho/godotenv"
+	"strconv"
+	"time"
 )
 
-func CreateConnection() *sql.DB {
-	// load .env file
-	err := godotenv.Load(".env")
+// Config holds the database credentials and the pool settings.
+type Config struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// ConfigFromEnv reads the config from the environment, the .env file must
+// already be loaded. Pool settings fall back to sane defaults.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Host:     os.Getenv("HOST"),
+		Port:     os.Getenv("PORT"),
+		User:     os.Getenv("USER"),
+		Password: os.Getenv("PASSWORD"),
+		Name:     os.Getenv("DBNAME"),
+	}
 
-	helpers.CheckError("Error loading env files (create connection)", err)
+	var err error
+	if cfg.MaxOpenConns, err = envInt("DB_MAX_OPEN_CONNS", 25); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxIdleConns, err = envInt("DB_MAX_IDLE_CONNS", 25); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnMaxLifetime, err = envDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnMaxIdleTime, err = envDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute); err != nil {
+		return cfg, err
+	}
 
-	// initialize db credential
-	DbHost := os.Getenv("HOST")
-	DbPort := os.Getenv("PORT")
-	DbUser := os.Getenv("USER")
-	DbPassword := os.Getenv("PASSWORD")
-	DbName := os.Getenv("DBNAME")
+	return cfg, nil
+}
 
-	DbUrl := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s", DbHost, DbPort, DbUser, DbName, DbPassword)
+// Open creates the connection pool shared by the whole application and
+// checks the database is reachable.
+func Open(cfg Config) (*sql.DB, error) {
+	DbUrl := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s", cfg.Host, cfg.Port, cfg.User, cfg.Name, cfg.Password)
 
-	// open the connection
+	// open the connection pool
 	db, err := sql.Open("postgres", DbUrl)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
 	// check the connection
 	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
 
+func envInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
 	if err != nil {
-		panic(err)
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
 	}
+	return n, nil
+}
 
-	// return the connection
-	return db
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return d, nil
 }
@@ -1,17 +1,118 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/controllers"
+	"github.com/f-chilmi/just-text-go/db"
+	"github.com/f-chilmi/just-text-go/middlewares"
+	"github.com/f-chilmi/just-text-go/realtime"
+	"github.com/f-chilmi/just-text-go/repository"
 	"github.com/f-chilmi/just-text-go/router"
+	"github.com/f-chilmi/just-text-go/storage"
+	"github.com/f-chilmi/just-text-go/thumbnails"
+	"github.com/joho/godotenv"
 )
 
 func main() {
-	r := router.Router()
+	migrate := flag.Bool("migrate", false, "apply pending schema migrations before serving")
+	flag.Parse()
+
+	// load .env file, variables already set in the environment win
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Printf("no .env file loaded. %v", err)
+	}
+
+	// `migrate up|down|status` manages the schema and exits
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(flag.Args()[1:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	// refuse to start without a jwt key
+	keys, err := auth.LoadKeys()
+	if err != nil {
+		log.Fatalf("unable to load the jwt keys. %v", err)
+	}
+
+	store, closeStore, err := openStore(os.Getenv("STORAGE"), *migrate)
+	if err != nil {
+		log.Fatalf("unable to open the %q storage. %v", os.Getenv("STORAGE"), err)
+	}
+	defer closeStore()
+
+	authenticator := auth.NewAuthenticator(keys, store.Tokens)
+
+	serverConfig, err := controllers.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("unable to read the server config. %v", err)
+	}
+
+	// uploaded files are kept on the local disk
+	attachmentsDir := os.Getenv("ATTACHMENTS_DIR")
+	if attachmentsDir == "" {
+		attachmentsDir = "attachments"
+	}
+	blobs, err := storage.NewLocalStore(attachmentsDir)
+	if err != nil {
+		log.Fatalf("unable to open the attachments storage. %v", err)
+	}
+
+	// previews of the image attachments are made in the background
+	thumbs := thumbnails.NewWorker(store.Attachments, blobs)
+	go thumbs.Run(time.Minute)
+
+	server := controllers.NewServer(store, authenticator, realtime.NewHub(), blobs, thumbs, serverConfig)
+	r := router.Router(server, middlewares.New(authenticator, store))
+
+	// drop revocation entries of tokens that have expired anyway
+	go authenticator.CollectRevokedTokens(time.Hour)
 
 	fmt.Println("Starting server on port 8080")
 
 	log.Fatal(http.ListenAndServe(":8080", r))
 }
+
+// openStore returns the repositories of the configured backend: postgres
+// (the default) or memory, which needs no database at all. With migrate
+// set, pending schema migrations are applied to postgres first.
+func openStore(kind string, migrate bool) (*repository.Store, func(), error) {
+	switch kind {
+	case "", "postgres":
+		// one pool shared by every repository
+		dbConfig, err := db.ConfigFromEnv()
+		if err != nil {
+			return nil, nil, err
+		}
+		pool, err := db.Open(dbConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		if migrate {
+			if err := migrateUp(pool); err != nil {
+				pool.Close()
+				return nil, nil, err
+			}
+		}
+		return repository.NewPostgresStore(pool), func() { pool.Close() }, nil
+
+	case "memory":
+		if migrate {
+			return nil, nil, fmt.Errorf("-migrate only applies to the postgres storage")
+		}
+		log.Println("using the in-memory storage, nothing is persisted")
+		return repository.NewMemoryStore(), func() {}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown storage, expected postgres or memory")
+	}
+}
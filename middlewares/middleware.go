@@ -1,11 +1,15 @@
 package middlewares
 
 import (
+	"database/sql"
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/f-chilmi/just-text-go/auth"
+	"github.com/f-chilmi/just-text-go/repository"
 	"github.com/f-chilmi/just-text-go/responses"
+	"github.com/gorilla/mux"
 )
 
 func SetMiddlewareJSON(next http.HandlerFunc) http.HandlerFunc {
@@ -16,13 +20,205 @@ func SetMiddlewareJSON(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func SetMiddlewareAuth(next http.HandlerFunc) http.HandlerFunc {
+// Middleware holds the dependencies of the middlewares that need more than
+// the request.
+type Middleware struct {
+	auth        *auth.Authenticator
+	rooms       repository.RoomRepository
+	messages    repository.MessageRepository
+	attachments repository.AttachmentRepository
+}
+
+func New(authenticator *auth.Authenticator, store *repository.Store) *Middleware {
+	return &Middleware{
+		auth:        authenticator,
+		rooms:       store.Rooms,
+		messages:    store.Messages,
+		attachments: store.Attachments,
+	}
+}
+
+func (m *Middleware) SetMiddlewareAuth(next http.HandlerFunc) http.HandlerFunc {
+	return setPrincipal(m.auth.Authenticate, next)
+}
+
+// SetMiddlewareStreamAuth is SetMiddlewareAuth for the websocket and sse
+// routes only, it also accepts the token in the ?token= query.
+func (m *Middleware) SetMiddlewareStreamAuth(next http.HandlerFunc) http.HandlerFunc {
+	return setPrincipal(m.auth.AuthenticateStream, next)
+}
+
+func setPrincipal(authenticate func(r *http.Request) (*auth.Principal, error), next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := authenticate(r)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			return
+		}
+		// handlers read the identity from the context instead of parsing the token again
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	}
+}
+
+// SetMiddlewareRoomMember only lets members of the room in the {id} route
+// variable through: 404 when the room does not exist, 403 when the caller
+// is not one of its members. It must run after SetMiddlewareAuth.
+func (m *Middleware) SetMiddlewareRoomMember(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idRoom, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+
+		me, err := auth.CurrentUser(r)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			return
+		}
+
+		_, err = m.rooms.FindRoomById(idRoom)
+		switch err {
+		case sql.ErrNoRows:
+			responses.ERROR(w, http.StatusNotFound, errors.New("room not found"))
+			return
+		case nil:
+			break
+		default:
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		member, err := m.rooms.IsMember(idRoom, me.ID)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !member {
+			responses.ERROR(w, http.StatusForbidden, errors.New("Forbidden"))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// SetMiddlewareMessageMember only lets members of the room of the message in
+// the {id} route variable through: 404 when the message does not exist, 403
+// when the caller is not a member of its room. It must run after
+// SetMiddlewareAuth.
+func (m *Middleware) SetMiddlewareMessageMember(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idMessage, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+
+		me, err := auth.CurrentUser(r)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			return
+		}
+
+		message, err := m.messages.GetMsg(idMessage)
+		switch err {
+		case sql.ErrNoRows:
+			responses.ERROR(w, http.StatusNotFound, errors.New("message not found"))
+			return
+		case nil:
+			break
+		default:
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		member, err := m.rooms.IsMember(message.IdRoom, me.ID)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !member {
+			responses.ERROR(w, http.StatusForbidden, errors.New("Forbidden"))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// SetMiddlewareAttachmentMember only lets members of the room an attachment
+// was uploaded to through, the attachment id being the {id} route variable:
+// 404 when it does not exist or is not sent yet and the caller did not
+// upload it, 403 when the caller is not a member of the room. It must run
+// after SetMiddlewareAuth.
+func (m *Middleware) SetMiddlewareAttachmentMember(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idAttachment, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+
+		me, err := auth.CurrentUser(r)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			return
+		}
+
+		attachment, err := m.attachments.GetAttachment(idAttachment)
+		switch err {
+		case sql.ErrNoRows:
+			responses.ERROR(w, http.StatusNotFound, errors.New("attachment not found"))
+			return
+		case nil:
+			break
+		default:
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// until it is sent, an attachment is only visible to its uploader
+		if attachment.IdMessage == 0 && attachment.IdUploader != me.ID {
+			responses.ERROR(w, http.StatusNotFound, errors.New("attachment not found"))
+			return
+		}
+
+		member, err := m.rooms.IsMember(attachment.IdRoom, me.ID)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !member {
+			responses.ERROR(w, http.StatusForbidden, errors.New("Forbidden"))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// SetMiddlewareOwner only lets the user whose id is in the {id} route
+// variable through. It must run after SetMiddlewareAuth.
+func SetMiddlewareOwner(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		err := auth.TokenValid(r)
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+
+		me, err := auth.CurrentUser(r)
 		if err != nil {
 			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
 			return
 		}
+
+		if id != me.ID {
+			responses.ERROR(w, http.StatusForbidden, errors.New("Forbidden"))
+			return
+		}
+
 		next(w, r)
 	}
 }
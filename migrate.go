@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/f-chilmi/just-text-go/db"
+	"github.com/f-chilmi/just-text-go/migrations"
+)
+
+// runMigrate handles the `migrate up|down|status` command.
+func runMigrate(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: migrate up|down|status")
+	}
+
+	dbConfig, err := db.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	pool, err := db.Open(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	switch args[0] {
+	case "up":
+		return migrateUp(pool)
+
+	case "down":
+		m, err := migrations.New(pool)
+		if err != nil {
+			return err
+		}
+		mig, done, err := m.Down()
+		if err != nil {
+			return err
+		}
+		if !done {
+			log.Println("no migration to roll back")
+			return nil
+		}
+		log.Printf("rolled back %04d_%s", mig.Version, mig.Name)
+		return nil
+
+	case "status":
+		m, err := migrations.New(pool)
+		if err != nil {
+			return err
+		}
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			applied := "pending"
+			if !s.AppliedAt.IsZero() {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-24s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q, expected up, down or status", args[0])
+	}
+}
+
+// migrateUp applies every pending migration.
+func migrateUp(pool *sql.DB) error {
+	m, err := migrations.New(pool)
+	if err != nil {
+		return err
+	}
+
+	applied, err := m.Up()
+	for _, mig := range applied {
+		log.Printf("applied %04d_%s", mig.Version, mig.Name)
+	}
+	return err
+}
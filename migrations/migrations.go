@@ -0,0 +1,215 @@
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// arbitrary key of the advisory lock taken while migrating, so two
+// instances starting together do not apply the same migration twice
+const lockKey = 4242001
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a numbered pair of up/down SQL scripts.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status of a migration, AppliedAt is zero when it is still pending.
+type Status struct {
+	Migration
+	AppliedAt time.Time
+}
+
+// Load returns the embedded migrations ordered by version.
+func Load() ([]Migration, error) {
+	entries, err := files.ReadDir("sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		match := fileName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migrations: unexpected file %s", e.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migrations: version %d has two names, %s and %s", version, m.Name, match[2])
+		}
+
+		content, err := files.ReadFile(path.Join("sql", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	var list []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrations: version %d needs both an up and a down file", m.Version)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+
+	return list, nil
+}
+
+// Migrator applies the embedded migrations and records them in the
+// schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB) (*Migrator, error) {
+	list, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version int PRIMARY KEY,
+			name VARCHAR (255) NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: list}, nil
+}
+
+// Up applies every pending migration in order, each one in its own
+// transaction, and returns the ones it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	var applied []Migration
+
+	for _, mig := range m.migrations {
+		done, err := m.apply(mig, true)
+		if err != nil {
+			return applied, fmt.Errorf("migration %04d_%s: %v", mig.Version, mig.Name, err)
+		}
+		if done {
+			applied = append(applied, mig)
+		}
+	}
+	return applied, nil
+}
+
+// Down rolls back the latest applied migration. It returns false when
+// there was nothing to roll back.
+func (m *Migrator) Down() (Migration, bool, error) {
+	status, err := m.Status()
+	if err != nil {
+		return Migration{}, false, err
+	}
+
+	for i := len(status) - 1; i >= 0; i-- {
+		if status[i].AppliedAt.IsZero() {
+			continue
+		}
+
+		mig := status[i].Migration
+		done, err := m.apply(mig, false)
+		if err != nil {
+			return mig, false, fmt.Errorf("migration %04d_%s: %v", mig.Version, mig.Name, err)
+		}
+		return mig, done, nil
+	}
+	return Migration{}, false, nil
+}
+
+func (m *Migrator) Status() ([]Status, error) {
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		status = append(status, Status{Migration: mig, AppliedAt: appliedAt[mig.Version]})
+	}
+	return status, nil
+}
+
+// apply runs the up (or down) script of the migration unless it was
+// already applied (or is not applied), under the advisory lock.
+func (m *Migrator) apply(mig Migration, up bool) (bool, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, lockKey); err != nil {
+		return false, err
+	}
+
+	var applied bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version=$1)`, mig.Version).Scan(&applied)
+	if err != nil {
+		return false, err
+	}
+	if applied == up {
+		return false, nil
+	}
+
+	if up {
+		if _, err = tx.Exec(mig.Up); err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mig.Version, mig.Name)
+	} else {
+		if _, err = tx.Exec(mig.Down); err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version=$1`, mig.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
@@ -0,0 +1,4 @@
+ALTER TABLE rooms DROP CONSTRAINT IF EXISTS rooms_id_last_msg_fkey;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS rooms;
+DROP TABLE IF EXISTS users;
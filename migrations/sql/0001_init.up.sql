@@ -0,0 +1,50 @@
+-- users, rooms and messages as they were created by hand before migrations
+-- existed, IF NOT EXISTS lets those databases adopt the migrations as is
+CREATE TABLE IF NOT EXISTS
+  users (
+    id serial PRIMARY KEY,
+    username VARCHAR (255) NOT NULL,
+    phone VARCHAR (255) NOT NULL UNIQUE,
+    password VARCHAR (255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+  );
+
+-- rooms and messages reference each other, the id_last_msg foreign key is
+-- added once messages exists
+CREATE TABLE IF NOT EXISTS
+  rooms (
+    id serial PRIMARY KEY,
+    id_user1 int NOT NULL,
+    id_user2 int NOT NULL,
+    id_last_msg int,
+    last_msg VARCHAR (255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (id_user1) REFERENCES users (id),
+    FOREIGN KEY (id_user2) REFERENCES users (id)
+  );
+
+CREATE TABLE IF NOT EXISTS
+  messages (
+    id serial PRIMARY KEY,
+    id_sender int NOT NULL,
+    id_recipient int NOT NULL,
+    content VARCHAR (255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    id_room int NOT NULL,
+    FOREIGN KEY (id_sender) REFERENCES users (id),
+    FOREIGN KEY (id_recipient) REFERENCES users (id),
+    FOREIGN KEY (id_room) REFERENCES rooms (id)
+  );
+
+-- id_last_msg is never set on insert, it must be nullable
+ALTER TABLE rooms ALTER COLUMN id_last_msg DROP NOT NULL;
+
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'rooms_id_last_msg_fkey') THEN
+    ALTER TABLE rooms ADD CONSTRAINT rooms_id_last_msg_fkey FOREIGN KEY (id_last_msg) REFERENCES messages (id);
+  END IF;
+END $$;
@@ -0,0 +1,15 @@
+-- group rooms cannot be represented without room_members
+-- their previews reference the messages
+UPDATE rooms SET id_last_msg = NULL WHERE is_group;
+DELETE FROM messages WHERE id_room IN (SELECT id FROM rooms WHERE is_group);
+DELETE FROM rooms WHERE is_group;
+
+DROP TABLE IF EXISTS room_members;
+
+ALTER TABLE messages ALTER COLUMN id_recipient SET NOT NULL;
+
+ALTER TABLE rooms
+  DROP COLUMN IF EXISTS name,
+  DROP COLUMN IF EXISTS is_group,
+  ALTER COLUMN id_user1 SET NOT NULL,
+  ALTER COLUMN id_user2 SET NOT NULL;
@@ -0,0 +1,28 @@
+-- id_user1/id_user2 are only kept for 1:1 rooms, members of every room live in room_members
+ALTER TABLE rooms
+  ADD COLUMN IF NOT EXISTS name VARCHAR (255) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS is_group BOOLEAN NOT NULL DEFAULT false,
+  ALTER COLUMN id_user1 DROP NOT NULL,
+  ALTER COLUMN id_user2 DROP NOT NULL;
+
+-- messages sent into a group have no single recipient
+ALTER TABLE messages ALTER COLUMN id_recipient DROP NOT NULL;
+
+CREATE TABLE IF NOT EXISTS
+  room_members (
+    id_room int NOT NULL,
+    id_user int NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id_room, id_user),
+    FOREIGN KEY (id_room) REFERENCES rooms (id) ON DELETE CASCADE,
+    FOREIGN KEY (id_user) REFERENCES users (id)
+  );
+
+CREATE INDEX IF NOT EXISTS room_members_id_user_idx ON room_members (id_user);
+
+-- members of the 1:1 rooms created before groups existed
+INSERT INTO room_members (id_room, id_user)
+  SELECT id, id_user1 FROM rooms WHERE NOT is_group
+  UNION
+  SELECT id, id_user2 FROM rooms WHERE NOT is_group
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,16 @@
+-- only the sha256 of the opaque token is stored, rotations share the family
+CREATE TABLE IF NOT EXISTS
+  refresh_tokens (
+    id serial PRIMARY KEY,
+    id_user int NOT NULL,
+    family VARCHAR (64) NOT NULL,
+    token_hash VARCHAR (64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    replaced_by int,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (id_user) REFERENCES users (id),
+    FOREIGN KEY (replaced_by) REFERENCES refresh_tokens (id)
+  );
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (family);
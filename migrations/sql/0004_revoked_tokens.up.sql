@@ -0,0 +1,18 @@
+-- access tokens killed by a logout, kept until they would have expired
+CREATE TABLE IF NOT EXISTS
+  revoked_tokens (
+    jti VARCHAR (64) PRIMARY KEY,
+    id_user int NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (id_user) REFERENCES users (id)
+  );
+
+-- every access token of the user issued up to revoked_before is revoked (logout from all sessions)
+CREATE TABLE IF NOT EXISTS
+  user_revocations (
+    id_user int PRIMARY KEY,
+    revoked_before TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (id_user) REFERENCES users (id)
+  );
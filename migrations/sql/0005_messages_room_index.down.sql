@@ -0,0 +1 @@
+DROP INDEX IF EXISTS messages_id_room_id_idx;
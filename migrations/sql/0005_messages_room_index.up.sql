@@ -0,0 +1,2 @@
+-- backs the cursor pagination of a room history
+CREATE INDEX IF NOT EXISTS messages_id_room_id_idx ON messages (id_room, id);
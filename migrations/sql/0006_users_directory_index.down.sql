@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_created_at_idx;
+DROP INDEX IF EXISTS users_phone_pattern_idx;
+DROP INDEX IF EXISTS users_lower_username_idx;
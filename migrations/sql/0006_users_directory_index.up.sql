@@ -0,0 +1,4 @@
+-- back the prefix search and the sort of the user directory
+CREATE INDEX IF NOT EXISTS users_lower_username_idx ON users (lower(username) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS users_phone_pattern_idx ON users (phone text_pattern_ops);
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at, id);
@@ -0,0 +1,2 @@
+-- last message of the room each member has read, 0 when none
+ALTER TABLE room_members ADD COLUMN IF NOT EXISTS last_read_message_id int NOT NULL DEFAULT 0;
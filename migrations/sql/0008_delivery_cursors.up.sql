@@ -0,0 +1,5 @@
+-- last message of the room delivered to a device of each member, 0 when
+-- none, a read message was delivered as well
+ALTER TABLE room_members ADD COLUMN IF NOT EXISTS last_delivered_message_id int NOT NULL DEFAULT 0;
+
+UPDATE room_members SET last_delivered_message_id = last_read_message_id;
@@ -0,0 +1,13 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited BOOLEAN NOT NULL DEFAULT false;
+
+-- prior versions of the edited messages
+CREATE TABLE IF NOT EXISTS
+  message_edits (
+    id serial PRIMARY KEY,
+    id_message int NOT NULL,
+    content VARCHAR (255) NOT NULL,
+    edited_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (id_message) REFERENCES messages (id) ON DELETE CASCADE
+  );
+
+CREATE INDEX IF NOT EXISTS message_edits_id_message_idx ON message_edits (id_message, id);
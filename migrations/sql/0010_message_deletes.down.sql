@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS message_hidden;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,13 @@
+-- messages deleted for everyone are kept as tombstones without content
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- messages deleted for a single user
+CREATE TABLE IF NOT EXISTS
+  message_hidden (
+    id_message int NOT NULL,
+    id_user int NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id_message, id_user),
+    FOREIGN KEY (id_message) REFERENCES messages (id) ON DELETE CASCADE,
+    FOREIGN KEY (id_user) REFERENCES users (id)
+  );
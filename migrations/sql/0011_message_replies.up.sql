@@ -0,0 +1,2 @@
+-- parent of a reply, always a message of the same room
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to_id int REFERENCES messages (id) ON DELETE SET NULL;
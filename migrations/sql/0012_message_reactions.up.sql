@@ -0,0 +1,11 @@
+-- one reaction per user per emoji
+CREATE TABLE IF NOT EXISTS
+  message_reactions (
+    id_message int NOT NULL,
+    id_user int NOT NULL,
+    emoji VARCHAR (64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id_message, id_user, emoji),
+    FOREIGN KEY (id_message) REFERENCES messages (id) ON DELETE CASCADE,
+    FOREIGN KEY (id_user) REFERENCES users (id)
+  );
@@ -0,0 +1,20 @@
+-- uploaded files, the content is in the blob storage under storage_key.
+-- id_message stays NULL until a message references the attachment
+CREATE TABLE IF NOT EXISTS
+  attachments (
+    id serial PRIMARY KEY,
+    id_room int NOT NULL,
+    id_uploader int NOT NULL,
+    id_message int,
+    file_name VARCHAR (255) NOT NULL,
+    mime_type VARCHAR (255) NOT NULL,
+    size bigint NOT NULL,
+    checksum CHAR (64) NOT NULL,
+    storage_key VARCHAR (255) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (id_room) REFERENCES rooms (id) ON DELETE CASCADE,
+    FOREIGN KEY (id_uploader) REFERENCES users (id),
+    FOREIGN KEY (id_message) REFERENCES messages (id) ON DELETE SET NULL
+  );
+
+CREATE INDEX IF NOT EXISTS attachments_id_message_idx ON attachments (id_message);
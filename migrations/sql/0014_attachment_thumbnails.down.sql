@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS attachments_thumbnail_pending_idx;
+
+ALTER TABLE attachments DROP COLUMN IF EXISTS thumbnail_status;
+ALTER TABLE attachments DROP COLUMN IF EXISTS thumbnail_key;
+ALTER TABLE attachments DROP COLUMN IF EXISTS height;
+ALTER TABLE attachments DROP COLUMN IF EXISTS width;
@@ -0,0 +1,12 @@
+-- dimensions and preview of the image attachments, filled by the thumbnail worker.
+-- thumbnail_status is NULL for files that are not images
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS width int;
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS height int;
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS thumbnail_key VARCHAR (255);
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS thumbnail_status VARCHAR (16);
+
+-- images uploaded before the worker existed get a thumbnail too
+UPDATE attachments SET thumbnail_status = 'pending'
+WHERE thumbnail_status IS NULL AND mime_type IN ('image/jpeg', 'image/png', 'image/gif');
+
+CREATE INDEX IF NOT EXISTS attachments_thumbnail_pending_idx ON attachments (id) WHERE thumbnail_status = 'pending';
@@ -0,0 +1,4 @@
+-- longer contents are cut to fit again
+ALTER TABLE rooms ALTER COLUMN last_msg TYPE VARCHAR (255) USING left(last_msg, 255);
+ALTER TABLE message_edits ALTER COLUMN content TYPE VARCHAR (255) USING left(content, 255);
+ALTER TABLE messages ALTER COLUMN content TYPE VARCHAR (255) USING left(content, 255);
@@ -0,0 +1,5 @@
+-- the length of a message is checked by the server (MESSAGE_MAX_LENGTH),
+-- the preview in rooms.last_msg is truncated by it too
+ALTER TABLE messages ALTER COLUMN content TYPE TEXT;
+ALTER TABLE message_edits ALTER COLUMN content TYPE TEXT;
+ALTER TABLE rooms ALTER COLUMN last_msg TYPE TEXT;
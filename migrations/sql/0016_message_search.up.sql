@@ -0,0 +1,6 @@
+-- full-text search over the messages. the simple configuration does not
+-- stem, chats mix languages
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search tsvector
+  GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+CREATE INDEX IF NOT EXISTS messages_search_idx ON messages USING GIN (search);
@@ -0,0 +1,8 @@
+-- the creator of a group is the only member allowed to remove the others
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS id_creator int REFERENCES users (id);
+
+-- groups created before are given to their first member
+UPDATE rooms SET id_creator = (
+  SELECT id_user FROM room_members WHERE id_room = rooms.id ORDER BY created_at, id_user LIMIT 1
+)
+WHERE is_group AND id_creator IS NULL;
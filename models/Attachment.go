@@ -0,0 +1,67 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// states of the thumbnail of an image attachment
+const (
+	ThumbnailPending = "pending"
+	ThumbnailReady   = "ready"
+	ThumbnailFailed  = "failed"
+)
+
+// Attachment is the metadata of an uploaded file, Checksum is the hex
+// sha256 of its content. Width and Height are only known for images once
+// their thumbnail is ready.
+type Attachment struct {
+	ID              int64     `json:"id"`
+	IdRoom          int64     `json:"id_room"`
+	IdUploader      int64     `json:"id_uploader"`
+	IdMessage       int64     `json:"id_message,omitempty"`
+	FileName        string    `json:"file_name"`
+	MimeType        string    `json:"mime_type"`
+	Size            int64     `json:"size"`
+	Checksum        string    `json:"checksum"`
+	StorageKey      string    `json:"-"`
+	URL             string    `json:"url"`
+	Width           int       `json:"width,omitempty"`
+	Height          int       `json:"height,omitempty"`
+	ThumbnailStatus string    `json:"thumbnail_status,omitempty"`
+	ThumbnailKey    string    `json:"-"`
+	ThumbnailURL    string    `json:"thumbnail_url,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// DownloadURL is the path the attachment is served from.
+func (a *Attachment) DownloadURL() string {
+	return "/attachments/" + strconv.FormatInt(a.ID, 10)
+}
+
+// WithURLs fills the paths the attachment and its thumbnail are served from.
+func (a *Attachment) WithURLs() {
+	a.URL = a.DownloadURL()
+	a.ThumbnailURL = ""
+	if a.ThumbnailStatus == ThumbnailReady {
+		a.ThumbnailURL = a.DownloadURL() + "/thumbnail"
+	}
+}
+
+// HasThumbnail tells whether a thumbnail is made for files of the type.
+func HasThumbnail(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// ThumbnailMimeType is the type of the thumbnail: photos stay jpeg, the
+// other images become png to keep their transparency.
+func (a *Attachment) ThumbnailMimeType() string {
+	if a.MimeType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
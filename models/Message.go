@@ -2,40 +2,120 @@ package models
 
 import (
 	"time"
+	"unicode/utf8"
+)
+
+// number of characters of the parent quoted in a reply
+const QuoteLength = 100
 
-	"github.com/f-chilmi/just-text-go/db"
+// number of characters of the last message kept as the preview of a room
+const PreviewLength = 100
+
+// status lifecycle of a message, from the point of view of its recipients
+const (
+	MessageSent      = "sent"
+	MessageDelivered = "delivered"
+	MessageRead      = "read"
 )
 
 type Message struct {
-	ID          int64     `json:"id"`
-	IdSender    int64     `json:"id_sender"`
-	IdRecipient int64     `json:"id_recipient"`
-	IdRoom      int64     `json:"id_room"`
-	Content     string    `json:"content"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64        `json:"id"`
+	IdSender    int64        `json:"id_sender"`
+	IdRecipient int64        `json:"id_recipient,omitempty"`
+	IdRoom      int64        `json:"id_room"`
+	Content     string       `json:"content"`
+	Edited      bool         `json:"edited"`
+	Deleted     bool         `json:"deleted"`
+	ReplyToId   int64        `json:"reply_to_id,omitempty"`
+	ReplyTo     *Quote       `json:"reply_to,omitempty"`
+	Reactions   []Reaction   `json:"reactions,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Status      string       `json:"status,omitempty"`
+	ReadBy      []int64      `json:"read_by,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
 }
 
-func (m *Message) NewMsg(message Message) (Message, error) {
-	// create the db connection
-	db := db.CreateConnection()
+// MessagePage is one page of a room history. NextCursor is the id to pass
+// as the same before/after cursor to get the following page, it is null
+// once there is nothing left in that direction.
+type MessagePage struct {
+	Data       []Message `json:"data"`
+	NextCursor *int64    `json:"next_cursor"`
+}
 
-	// close the db connection
-	defer db.Close()
+// RecipientStatus is the status of a message for one of its recipients.
+type RecipientStatus struct {
+	IdUser int64  `json:"id_user"`
+	Status string `json:"status"`
+}
 
-	// create the insert query
-	// returning userid will return the id of the inserted user
-	sqlStatement := `INSERT INTO messages (id_sender, id_recipient, id_room, content) VALUES ($1, $2, $3, $4) RETURNING *;`
+// MessageStatus details the status of a message per recipient, Status is
+// the one every recipient has at least reached.
+type MessageStatus struct {
+	IdMessage  int64             `json:"id_message"`
+	IdRoom     int64             `json:"id_room"`
+	Status     string            `json:"status"`
+	Recipients []RecipientStatus `json:"recipients"`
+}
 
-	// inserted id will store in this id
-	var messages Message
+// MessageEdit is a prior version of an edited message.
+type MessageEdit struct {
+	ID        int64     `json:"id"`
+	IdMessage int64     `json:"id_message"`
+	Content   string    `json:"content"`
+	EditedAt  time.Time `json:"edited_at"`
+}
 
-	// execute the sql statement
-	// scan function will save the inserted id in the id
-	row := db.QueryRow(sqlStatement, message.IdSender, message.IdRecipient, message.IdRoom, message.Content)
+// Quote is the compact preview of the parent of a reply.
+type Quote struct {
+	ID       int64  `json:"id"`
+	IdSender int64  `json:"id_sender"`
+	Username string `json:"username"`
+	Content  string `json:"content"`
+	Deleted  bool   `json:"deleted"`
+}
 
-	err := row.Scan(&messages.ID, &messages.IdSender, &messages.IdRecipient, &messages.Content, &messages.CreatedAt, &messages.UpdatedAt, &message.IdRoom)
+// Reaction counts the users who reacted to a message with an emoji, Me is
+// set when the current user is one of them.
+type Reaction struct {
+	Emoji string `json:"emoji"`
+	Count int64  `json:"count"`
+	Me    bool   `json:"me"`
+}
+
+// SearchHit is a message matching a search. Snippet is the part of its
+// content around the match, html escaped with the matched words wrapped in
+// <mark></mark>. RoomName is only set for group rooms.
+type SearchHit struct {
+	IdMessage      int64     `json:"id_message"`
+	IdRoom         int64     `json:"id_room"`
+	RoomName       string    `json:"room_name,omitempty"`
+	IdSender       int64     `json:"id_sender"`
+	SenderUsername string    `json:"sender_username"`
+	Snippet        string    `json:"snippet"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SearchPage is one page of search hits, newest first. NextCursor is the
+// id to pass as before to get the following page, it is null on the last
+// page.
+type SearchPage struct {
+	Data       []SearchHit `json:"data"`
+	NextCursor *int64      `json:"next_cursor"`
+}
+
+// Preview is the content shown in the room list.
+func (m *Message) Preview() string {
+	return Truncate(m.Content, PreviewLength)
+}
 
-	// return the inserted message
-	return messages, err
+// Truncate cuts s to at most max characters (not bytes), ending it with an
+// ellipsis when it was longer.
+func Truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
 }
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RefreshToken is the stored part of an opaque refresh token, only its hash
+// is kept. Every rotation creates a new token in the same family.
+type RefreshToken struct {
+	ID         int64        `json:"id"`
+	IdUser     int64        `json:"id_user"`
+	Family     string       `json:"family"`
+	TokenHash  string       `json:"-"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	RevokedAt  sql.NullTime `json:"-"`
+	ReplacedBy int64        `json:"replaced_by,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+type ReqRefreshToken struct {
+	RefreshToken string `json:"refresh_token"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// RevokedToken is an access token killed before its exp claim.
+type RevokedToken struct {
+	Jti       string    `json:"jti"`
+	IdUser    int64     `json:"id_user"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
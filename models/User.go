@@ -2,20 +2,18 @@ package models
 
 import (
 	"errors"
-	"fmt"
 	"html"
 	"strings"
 	"time"
-
-	"github.com/f-chilmi/just-text-go/db"
-	"github.com/f-chilmi/just-text-go/helpers"
 )
 
+// User is the stored user record, the password hash never leaves the
+// server: responses use PublicUser or SelfUser.
 type User struct {
 	ID        int64     `json:"id"`
 	Username  string    `json:"username"`
 	Phone     string    `json:"phone"`
-	Password  string    `json:"password"`
+	Password  string    `json:"-"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -25,6 +23,43 @@ type UserData struct {
 	Phone    string `json:"phone"`
 }
 
+// Credentials is the body of the register and login requests, the only
+// place a password is read from.
+type Credentials struct {
+	UserData
+	Password string `json:"password"`
+}
+
+// PublicUser is what other users get to see of a user.
+type PublicUser struct {
+	ID int64 `json:"id"`
+	UserData
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SelfUser is what a user gets to see of their own account.
+type SelfUser struct {
+	PublicUser
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserQuery filters and orders the user directory. Q is a prefix of the
+// username or the phone, Sort is username or created_at.
+type UserQuery struct {
+	Q      string
+	Sort   string
+	Desc   bool
+	Limit  int
+	Offset int
+}
+
+// UserPage is one page of the user directory, NextOffset is null on the
+// last page.
+type UserPage struct {
+	Data       []PublicUser `json:"data"`
+	NextOffset *int         `json:"next_offset"`
+}
+
 type GenerateTokenRes struct {
 	ID       int64  `json:"id"`
 	Phone    string `json:"phone"`
@@ -33,20 +68,34 @@ type GenerateTokenRes struct {
 }
 
 type ResLoginWithToken struct {
-	ID       int64  `json:"id"`
-	Phone    string `json:"phone"`
-	Username string `json:"username"`
-	Exp      int64  `json:"exp"`
-	Token    string `json:"token"`
+	ID           int64  `json:"id"`
+	Phone        string `json:"phone"`
+	Username     string `json:"username"`
+	Exp          int64  `json:"exp"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	RefreshExp   int64  `json:"refresh_exp"`
 }
 
-func (u *User) Prepare() {
+func (u *User) Public() PublicUser {
+	return PublicUser{
+		ID:        u.ID,
+		UserData:  UserData{Username: u.Username, Phone: u.Phone},
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+func (u *User) Self() SelfUser {
+	return SelfUser{PublicUser: u.Public(), UpdatedAt: u.UpdatedAt}
+}
+
+func (u *Credentials) Prepare() {
 	u.Username = html.EscapeString(strings.TrimSpace(u.Username))
 	u.Phone = html.EscapeString(strings.TrimSpace(u.Phone))
 	u.Password = html.EscapeString(strings.TrimSpace(u.Password))
 }
 
-func (u *User) Validate(action string) error {
+func (u *Credentials) Validate(action string) error {
 	switch strings.ToLower(action) {
 	case "update":
 		switch "" {
@@ -95,123 +144,3 @@ func (u *User) Validate(action string) error {
 		}
 	}
 }
-
-func (u *User) InsertUser(user User) (int64, error) {
-	db := db.CreateConnection()
-	defer db.Close()
-
-	sqlStatement := `INSERT INTO users (username, phone, password) VALUES ($1, $2, $3) RETURNING id;`
-
-	var id int64
-	err := db.QueryRow(sqlStatement, user.Username, user.Phone, user.Password).Scan(&id)
-
-	return id, err
-}
-
-func (u *User) GetUsers() ([]User, error) {
-	// create the postgres db connection
-	db := db.CreateConnection()
-
-	// close the db connection
-	defer db.Close()
-
-	var users []User
-
-	// create the select sql query
-	sqlStatement := `SELECT * FROM users`
-
-	// execute the sql statement
-	rows, err := db.Query(sqlStatement)
-
-	helpers.CheckError("Unable to execute the query.", err)
-
-	// close the statement
-	defer rows.Close()
-
-	// iterate over the rows
-	for rows.Next() {
-		var user User
-
-		// unmarshal the row object to user
-		err := rows.Scan(&user.ID, &user.Username, &user.Phone, &user.Password, &user.CreatedAt, &user.UpdatedAt)
-
-		helpers.CheckError("Unable to scan the row.", err)
-
-		// append the user in the users slice
-		users = append(users, user)
-
-	}
-
-	// return empty user on error
-	return users, err
-}
-
-func (u *User) GetUser(id int64) (User, error) {
-	// create the postgres db connection
-	db := db.CreateConnection()
-
-	// close the db connection
-	defer db.Close()
-
-	// create a user of models.User type
-	var user User
-
-	// create the select sql query
-	sqlStatement := `SELECT * FROM users WHERE id=$1`
-
-	// execute the sql statement
-	row := db.QueryRow(sqlStatement, id)
-
-	// unmarshal the row object to user
-	err := row.Scan(&user.ID, &user.Username, &user.Phone, &user.Password, &user.CreatedAt, &user.UpdatedAt)
-
-	// return empty user on error
-	return user, err
-}
-
-func (u *User) UpdateUser(id int64, user User) int64 {
-
-	// create the postgres db connection
-	db := db.CreateConnection()
-
-	// close the db connection
-	defer db.Close()
-
-	// create the update sql query
-	sqlStatement := `UPDATE users SET username=$2, phone=$3, updated_at=CURRENT_TIMESTAMP WHERE id=$1`
-
-	// execute the sql statement
-	res, err := db.Exec(sqlStatement, id, user.Username, user.Phone)
-
-	helpers.CheckError("Unable to execute the query", err)
-
-	// check how many rows affected
-	rowsAffected, err := res.RowsAffected()
-
-	helpers.CheckError("Error while cheking the affected rows", err)
-
-	fmt.Printf("Total rows/record affected %v", rowsAffected)
-
-	return rowsAffected
-}
-
-func (u *User) GetUserByPhone(phone string) (User, error) {
-	// create the postgres db connection
-	db := db.CreateConnection()
-
-	// close the db connection
-	defer db.Close()
-
-	var user User
-
-	// create the select sql query
-	sqlStatement := `SELECT * FROM users WHERE phone=$1`
-
-	// execute the sql statement
-	row := db.QueryRow(sqlStatement, phone)
-
-	err := row.Scan(&user.ID, &user.Username, &user.Phone, &user.Password, &user.CreatedAt, &user.UpdatedAt)
-
-	// return empty user on error
-	return user, err
-}
@@ -0,0 +1,98 @@
+package realtime
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+
+	// time allowed to read the next pong message from the peer
+	pongWait = 60 * time.Second
+
+	// send pings to peer with this period, must be less than pongWait
+	pingPeriod = (pongWait * 9) / 10
+
+	// maximum message size allowed from peer
+	maxMessageSize = 512
+)
+
+// Client is a single websocket connection of an authenticated user.
+type Client struct {
+	hub       *Hub
+	conn      *websocket.Conn
+	sub       *Subscriber
+	delivered func(Event)
+}
+
+// ServeClient subscribes the connection to the hub and blocks until it is
+// closed by either side. delivered, when set, is called with every event
+// written to the connection.
+func ServeClient(h *Hub, conn *websocket.Conn, userId int64, delivered func(Event)) {
+	c := &Client{
+		hub:       h,
+		conn:      conn,
+		sub:       h.Subscribe(userId),
+		delivered: delivered,
+	}
+
+	go c.writePump()
+	c.readPump()
+}
+
+// readPump only exists to process control frames (pong, close). Anything
+// the client sends is ignored, messages are posted through the REST API.
+func (c *Client) readPump() {
+	defer func() {
+		c.hub.Unsubscribe(c.sub)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-c.sub.Events():
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// the hub dropped the subscriber
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteJSON(event); err != nil {
+				return
+			}
+			if c.delivered != nil {
+				c.delivered(event)
+			}
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
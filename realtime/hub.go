@@ -0,0 +1,115 @@
+package realtime
+
+import (
+	"sync"
+)
+
+// Event is the envelope pushed to connected clients. ID is only set for
+// EventMessageNew, the only event that can be replayed, to the id of the
+// message.
+type Event struct {
+	ID   int64       `json:"id,omitempty"`
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+const (
+	EventMessageNew      = "message.new"
+	EventMessageEdited   = "message.edited"
+	EventMessageDeleted  = "message.deleted"
+	EventMessageReaction = "message.reaction"
+	EventRoomCreated     = "room.created"
+	EventRoomLastMsg     = "room.last_msg"
+	EventRoomMembers     = "room.members"
+	EventRoomRead        = "room.read"
+	EventRoomDelivered   = "room.delivered"
+)
+
+// number of events buffered per subscriber before it is dropped
+const sendBuffer = 256
+
+// Subscriber is one live stream (websocket or sse) of a user.
+type Subscriber struct {
+	userId int64
+	send   chan Event
+}
+
+// Events is closed once the subscriber is removed from the hub.
+func (s *Subscriber) Events() <-chan Event {
+	return s.send
+}
+
+// Hub keeps track of every live stream per user. A user can be connected
+// from several devices at once, and a reconnecting device simply subscribes
+// again while the stale stream is dropped by its transport.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[int64]map[*Subscriber]bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[int64]map[*Subscriber]bool),
+	}
+}
+
+func (h *Hub) Subscribe(userId int64) *Subscriber {
+	s := &Subscriber{
+		userId: userId,
+		send:   make(chan Event, sendBuffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[userId] == nil {
+		h.subscribers[userId] = make(map[*Subscriber]bool)
+	}
+	h.subscribers[userId][s] = true
+
+	return s
+}
+
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs, ok := h.subscribers[s.userId]
+	if !ok || !subs[s] {
+		return
+	}
+
+	delete(subs, s)
+	close(s.send)
+	if len(subs) == 0 {
+		delete(h.subscribers, s.userId)
+	}
+}
+
+// Publish sends the event to every stream of the given users. Subscribers
+// whose buffer is full are considered dead and get disconnected.
+func (h *Hub) Publish(userIds []int64, e Event) {
+	var slow []*Subscriber
+
+	h.mu.RLock()
+	seen := make(map[int64]bool)
+	for _, id := range userIds {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		for s := range h.subscribers[id] {
+			select {
+			case s.send <- e:
+			default:
+				slow = append(slow, s)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range slow {
+		h.Unsubscribe(s)
+	}
+}
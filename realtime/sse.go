@@ -0,0 +1,24 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteSSE writes the event in the text/event-stream format. The data line
+// only carries the payload, the type goes into the "event" field.
+func WriteSSE(w io.Writer, e Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+
+	if e.ID != 0 {
+		if _, err = fmt.Fprintf(w, "id: %d\n", e.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	return err
+}
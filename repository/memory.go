@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"github.com/f-chilmi/just-text-go/models"
+)
+
+// memoryDB is the shared state of the in-memory backend, every repository
+// of a memory store locks the same mutex like they would share a database.
+type memoryDB struct {
+	mu sync.RWMutex
+
+	users       map[int64]models.User
+	rooms       map[int64]models.RoomDb
+	members     map[int64]map[int64]roomMember
+	messages    []models.Message
+	edits       []models.MessageEdit
+	hidden      map[int64]map[int64]bool
+	reactions   []messageReaction
+	attachments []models.Attachment
+
+	refreshTokens   map[int64]models.RefreshToken
+	revokedTokens   map[string]models.RevokedToken
+	userRevocations map[int64]userRevocation
+
+	// serial ids, per table like postgres
+	lastUserId       int64
+	lastRoomId       int64
+	lastMessageId    int64
+	lastEditId       int64
+	lastAttachmentId int64
+	lastTokenId      int64
+}
+
+// messageReaction is a message_reactions row, they are appended in the
+// order they were added
+type messageReaction struct {
+	idMessage int64
+	idUser    int64
+	emoji     string
+}
+
+// roomMember is a room_members row
+type roomMember struct {
+	joinedAt      time.Time
+	lastDelivered int64
+	lastRead      int64
+}
+
+type userRevocation struct {
+	revokedBefore time.Time
+	expiresAt     time.Time
+}
+
+// NewMemoryStore returns repositories keeping everything in memory, for
+// tests and local development without a database. Nothing survives a
+// restart.
+func NewMemoryStore() *Store {
+	m := &memoryDB{
+		users:           make(map[int64]models.User),
+		rooms:           make(map[int64]models.RoomDb),
+		members:         make(map[int64]map[int64]roomMember),
+		hidden:          make(map[int64]map[int64]bool),
+		refreshTokens:   make(map[int64]models.RefreshToken),
+		revokedTokens:   make(map[string]models.RevokedToken),
+		userRevocations: make(map[int64]userRevocation),
+	}
+
+	return &Store{
+		Users:       &memUserRepository{m},
+		Rooms:       &memRoomRepository{m},
+		Messages:    &memMessageRepository{m},
+		Attachments: &memAttachmentRepository{m},
+		Tokens:      &memTokenRepository{m},
+	}
+}
+
+// now mimics the CURRENT_TIMESTAMP of a TIMESTAMP column read back by lib/pq
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
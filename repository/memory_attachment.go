@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/f-chilmi/just-text-go/models"
+)
+
+type memAttachmentRepository struct {
+	*memoryDB
+}
+
+func (ar *memAttachmentRepository) InsertAttachment(a models.Attachment) (models.Attachment, error) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	ar.lastAttachmentId++
+	a.ID = ar.lastAttachmentId
+	a.IdMessage = 0
+	a.CreatedAt = now()
+	ar.attachments = append(ar.attachments, a)
+
+	return a, nil
+}
+
+func (ar *memAttachmentRepository) GetAttachment(id int64) (models.Attachment, error) {
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	for _, a := range ar.attachments {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return models.Attachment{}, sql.ErrNoRows
+}
+
+func (ar *memAttachmentRepository) ListAttachments(idMessages []int64) (map[int64][]models.Attachment, error) {
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	wanted := make(map[int64]bool)
+	for _, id := range idMessages {
+		wanted[id] = true
+	}
+
+	attachments := make(map[int64][]models.Attachment)
+	for _, a := range ar.attachments {
+		if a.IdMessage != 0 && wanted[a.IdMessage] {
+			attachments[a.IdMessage] = append(attachments[a.IdMessage], a)
+		}
+	}
+	return attachments, nil
+}
+
+func (ar *memAttachmentRepository) DeleteMsgAttachments(idMessage int64) ([]models.Attachment, error) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	var deleted, kept []models.Attachment
+	for _, a := range ar.attachments {
+		if a.IdMessage == idMessage {
+			deleted = append(deleted, a)
+		} else {
+			kept = append(kept, a)
+		}
+	}
+	ar.attachments = kept
+
+	return deleted, nil
+}
+
+func (ar *memAttachmentRepository) ListPendingThumbnails(limit int) ([]models.Attachment, error) {
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	var attachments []models.Attachment
+	for _, a := range ar.attachments {
+		if len(attachments) == limit {
+			break
+		}
+		if a.ThumbnailStatus == models.ThumbnailPending {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments, nil
+}
+
+func (ar *memAttachmentRepository) SetThumbnail(id int64, width int, height int, thumbnailKey string, status string) (int64, error) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	for i, a := range ar.attachments {
+		if a.ID == id {
+			ar.attachments[i].Width = width
+			ar.attachments[i].Height = height
+			ar.attachments[i].ThumbnailKey = thumbnailKey
+			ar.attachments[i].ThumbnailStatus = status
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,393 @@
+package repository
+
+import (
+	"database/sql"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/f-chilmi/just-text-go/models"
+)
+
+type memMessageRepository struct {
+	*memoryDB
+}
+
+func (m *memMessageRepository) SendMsg(message models.Message, attachmentIds []int64) (models.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// every attachment is checked before anything changes, like a rollback
+	m.lastMessageId++
+	message.ID = m.lastMessageId
+	wanted := make(map[int64]bool)
+	for _, id := range attachmentIds {
+		wanted[id] = true
+	}
+	var linked []int
+	for i, a := range m.attachments {
+		if wanted[a.ID] && a.IdUploader == message.IdSender && a.IdRoom == message.IdRoom && a.IdMessage == 0 {
+			linked = append(linked, i)
+		}
+	}
+	if len(linked) != len(attachmentIds) {
+		m.lastMessageId--
+		return models.Message{}, ErrAttachmentTaken
+	}
+
+	message.CreatedAt = now()
+	message.UpdatedAt = message.CreatedAt
+	m.messages = append(m.messages, message)
+
+	for _, i := range linked {
+		m.attachments[i].IdMessage = message.ID
+	}
+
+	if r, ok := m.rooms[message.IdRoom]; ok {
+		r.LastMsg = message.Preview()
+		r.IdLastMsg = message.ID
+		r.UpdatedAt = message.CreatedAt
+		m.rooms[message.IdRoom] = r
+	}
+
+	if member, ok := m.members[message.IdRoom][message.IdSender]; ok {
+		member.lastRead = message.ID
+		member.lastDelivered = message.ID
+		m.members[message.IdRoom][message.IdSender] = member
+	}
+
+	return message, nil
+}
+
+func (m *memMessageRepository) GetMsg(id int64) (models.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i, ok := m.messageIndex(id)
+	if !ok {
+		return models.Message{}, sql.ErrNoRows
+	}
+	return m.messages[i], nil
+}
+
+// MsgSentWithin compares against the clock of the process, there is no other.
+func (m *memMessageRepository) MsgSentWithin(id int64, window time.Duration) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i, ok := m.messageIndex(id)
+	if !ok {
+		return false, sql.ErrNoRows
+	}
+	return time.Since(m.messages[i].CreatedAt) < window, nil
+}
+
+// messageIndex expects the lock to be held, messages are appended in id order
+func (m *memoryDB) messageIndex(id int64) (int, bool) {
+	i := sort.Search(len(m.messages), func(i int) bool { return m.messages[i].ID >= id })
+	return i, i < len(m.messages) && m.messages[i].ID == id
+}
+
+func (m *memMessageRepository) ListQuotes(ids []int64) (map[int64]models.Quote, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	quotes := make(map[int64]models.Quote)
+	for _, id := range ids {
+		i, ok := m.messageIndex(id)
+		if !ok {
+			continue
+		}
+		chat := m.messages[i]
+		quotes[id] = models.Quote{
+			ID:       chat.ID,
+			IdSender: chat.IdSender,
+			Username: m.users[chat.IdSender].Username,
+			Content:  chat.Content,
+			Deleted:  chat.Deleted,
+		}
+	}
+	return quotes, nil
+}
+
+func (m *memMessageRepository) AddReaction(id int64, idUser int64, emoji string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reaction := messageReaction{idMessage: id, idUser: idUser, emoji: emoji}
+	for _, r := range m.reactions {
+		if r == reaction {
+			return nil
+		}
+	}
+	m.reactions = append(m.reactions, reaction)
+
+	return nil
+}
+
+func (m *memMessageRepository) RemoveReaction(id int64, idUser int64, emoji string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reaction := messageReaction{idMessage: id, idUser: idUser, emoji: emoji}
+	for i, r := range m.reactions {
+		if r == reaction {
+			m.reactions = append(m.reactions[:i], m.reactions[i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *memMessageRepository) ListReactions(ids []int64, idUser int64) (map[int64][]models.Reaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wanted := make(map[int64]bool)
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	reactions := make(map[int64][]models.Reaction)
+	for _, r := range m.reactions {
+		if !wanted[r.idMessage] {
+			continue
+		}
+
+		// emojis keep the position of their first reaction
+		list := reactions[r.idMessage]
+		i := 0
+		for i < len(list) && list[i].Emoji != r.emoji {
+			i++
+		}
+		if i == len(list) {
+			list = append(list, models.Reaction{Emoji: r.emoji})
+		}
+		list[i].Count++
+		list[i].Me = list[i].Me || r.idUser == idUser
+		reactions[r.idMessage] = list
+	}
+	return reactions, nil
+}
+
+func (m *memMessageRepository) EditMsg(id int64, content string) (models.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, ok := m.messageIndex(id)
+	if !ok {
+		return models.Message{}, sql.ErrNoRows
+	}
+
+	at := now()
+	m.lastEditId++
+	m.edits = append(m.edits, models.MessageEdit{
+		ID:        m.lastEditId,
+		IdMessage: id,
+		Content:   m.messages[i].Content,
+		EditedAt:  at,
+	})
+
+	m.messages[i].Content = content
+	m.messages[i].Edited = true
+	m.messages[i].UpdatedAt = at
+
+	return m.messages[i], nil
+}
+
+func (m *memMessageRepository) ListMsgEdits(id int64) ([]models.MessageEdit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var edits []models.MessageEdit
+	for _, edit := range m.edits {
+		if edit.IdMessage == id {
+			edits = append(edits, edit)
+		}
+	}
+	return edits, nil
+}
+
+func (m *memMessageRepository) OpenRoomChat(idRoom int64, idUser int64, before int64, after int64, limit int) ([]models.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// messages are appended in id order
+	var chats []models.Message
+	for _, chat := range m.messages {
+		if chat.IdRoom != idRoom || chat.ID <= after || (before > 0 && chat.ID >= before) {
+			continue
+		}
+		if m.hidden[chat.ID][idUser] {
+			continue
+		}
+		chats = append(chats, chat)
+		if after > 0 && len(chats) == limit {
+			break
+		}
+	}
+
+	// going backwards only the latest ones are kept
+	if after <= 0 && len(chats) > limit {
+		chats = chats[len(chats)-limit:]
+	}
+	return chats, nil
+}
+
+func (m *memMessageRepository) DeleteMsg(id int64) (models.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, ok := m.messageIndex(id)
+	if !ok {
+		return models.Message{}, sql.ErrNoRows
+	}
+
+	var edits []models.MessageEdit
+	for _, edit := range m.edits {
+		if edit.IdMessage != id {
+			edits = append(edits, edit)
+		}
+	}
+	m.edits = edits
+
+	var reactions []messageReaction
+	for _, r := range m.reactions {
+		if r.idMessage != id {
+			reactions = append(reactions, r)
+		}
+	}
+	m.reactions = reactions
+
+	m.messages[i].Content = ""
+	m.messages[i].Deleted = true
+	m.messages[i].UpdatedAt = now()
+
+	return m.messages[i], nil
+}
+
+func (m *memMessageRepository) HideMsg(id int64, idUser int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.hidden[id] == nil {
+		m.hidden[id] = make(map[int64]bool)
+	}
+	m.hidden[id][idUser] = true
+
+	return nil
+}
+
+func (m *memMessageRepository) LatestMsg(idRoom int64) (models.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].IdRoom == idRoom && !m.messages[i].Deleted {
+			return m.messages[i], nil
+		}
+	}
+	return models.Message{}, sql.ErrNoRows
+}
+
+func (m *memMessageRepository) ListMsgSince(idUser int64, lastId int64, limit int) ([]models.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// messages are appended in id order
+	var chats []models.Message
+	for _, chat := range m.messages {
+		if len(chats) >= limit {
+			break
+		}
+		if chat.ID <= lastId || m.hidden[chat.ID][idUser] {
+			continue
+		}
+		if _, ok := m.members[chat.IdRoom][idUser]; ok {
+			chats = append(chats, chat)
+		}
+	}
+	return chats, nil
+}
+
+// SearchMsg is a case-insensitive substring match, there is no full-text
+// index in memory.
+func (m *memMessageRepository) SearchMsg(idUser int64, q string, before int64, limit int) ([]models.SearchHit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var hits []models.SearchHit
+	for i := len(m.messages) - 1; i >= 0 && len(hits) < limit; i-- {
+		chat := m.messages[i]
+		if (before > 0 && chat.ID >= before) || chat.Deleted || m.hidden[chat.ID][idUser] {
+			continue
+		}
+		if _, ok := m.members[chat.IdRoom][idUser]; !ok {
+			continue
+		}
+
+		snippet, ok := highlight(chat.Content, q)
+		if !ok {
+			continue
+		}
+		hits = append(hits, models.SearchHit{
+			IdMessage:      chat.ID,
+			IdRoom:         chat.IdRoom,
+			RoomName:       m.rooms[chat.IdRoom].Name,
+			IdSender:       chat.IdSender,
+			SenderUsername: m.users[chat.IdSender].Username,
+			Snippet:        snippet,
+			CreatedAt:      chat.CreatedAt,
+		})
+	}
+	return hits, nil
+}
+
+// number of characters kept around the match of a snippet
+const (
+	snippetBefore = 30
+	snippetAfter  = 60
+)
+
+// highlight returns the content around the first case-insensitive match
+// of q, html escaped with the match wrapped in <mark></mark> like
+// ts_headline does.
+func highlight(content string, q string) (string, bool) {
+	text, pattern := []rune(content), []rune(strings.ToLower(q))
+
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	at := -1
+	for i := 0; i+len(pattern) <= len(lower); i++ {
+		if string(lower[i:i+len(pattern)]) == string(pattern) {
+			at = i
+			break
+		}
+	}
+	if at < 0 || len(pattern) == 0 {
+		return "", false
+	}
+	end := at + len(pattern)
+
+	from, to := at-snippetBefore, end+snippetAfter
+	prefix, suffix := "", ""
+	if from <= 0 {
+		from = 0
+	} else {
+		prefix = "…"
+	}
+	if to >= len(text) {
+		to = len(text)
+	} else {
+		suffix = "…"
+	}
+
+	return prefix + html.EscapeString(string(text[from:at])) +
+		"<mark>" + html.EscapeString(string(text[at:end])) + "</mark>" +
+		html.EscapeString(string(text[end:to])) + suffix, true
+}
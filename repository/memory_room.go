@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/f-chilmi/just-text-go/models"
+)
+
+type memRoomRepository struct {
+	*memoryDB
+}
+
+// roomList joins the room with its 1:1 users, it expects the lock to be held
+func (m *memoryDB) roomList(r models.RoomDb) models.RoomList {
+	room := models.RoomList{
+		ID:        r.ID,
+		Name:      r.Name,
+		IsGroup:   r.IsGroup,
+		IdCreator: r.IdCreator,
+		IdUser1:   r.IdUser1,
+		IdUser2:   r.IdUser2,
+		IdLastMsg: r.IdLastMsg,
+		LastMsg:   r.LastMsg,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+	if u, ok := m.users[r.IdUser1]; ok {
+		room.Username1 = u.Username
+		room.Phone1 = u.Phone
+	}
+	if u, ok := m.users[r.IdUser2]; ok {
+		room.Username2 = u.Username
+		room.Phone2 = u.Phone
+	}
+	return room
+}
+
+func (ru *memRoomRepository) FindRoom(myId int64, idUser2 int64) (models.Room, error) {
+	ru.mu.RLock()
+	defer ru.mu.RUnlock()
+
+	for _, r := range ru.rooms {
+		if r.IsGroup {
+			continue
+		}
+		if (r.IdUser1 == myId && r.IdUser2 == idUser2) || (r.IdUser1 == idUser2 && r.IdUser2 == myId) {
+			room := ru.roomList(r)
+			return room.ToRoom(myId), nil
+		}
+	}
+	return models.Room{}, sql.ErrNoRows
+}
+
+func (ru *memRoomRepository) FindRoomById(id int64) (models.RoomList, error) {
+	ru.mu.RLock()
+	defer ru.mu.RUnlock()
+
+	r, ok := ru.rooms[id]
+	if !ok {
+		return models.RoomList{}, sql.ErrNoRows
+	}
+	return ru.roomList(r), nil
+}
+
+func (ru *memRoomRepository) ListRoomByToken(idUser int64) ([]models.Room, error) {
+	ru.mu.RLock()
+	defer ru.mu.RUnlock()
+
+	var rooms []models.Room
+	for id, r := range ru.rooms {
+		if _, ok := ru.members[id][idUser]; !ok {
+			continue
+		}
+		list := ru.roomList(r)
+		room := list.ToRoom(idUser)
+		room.UnreadCount = ru.unreadCount(id, idUser)
+		rooms = append(rooms, room)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+
+	return rooms, nil
+}
+
+func (ru *memRoomRepository) NewRoom(r models.RoomDb) (int64, error) {
+	return ru.insertRoom(r, []int64{r.IdUser1, r.IdUser2})
+}
+
+func (ru *memRoomRepository) NewGroup(r models.RoomDb, memberIds []int64) (int64, error) {
+	r.IsGroup = true
+	r.IdUser1 = 0
+	r.IdUser2 = 0
+	return ru.insertRoom(r, memberIds)
+}
+
+func (ru *memRoomRepository) insertRoom(r models.RoomDb, memberIds []int64) (int64, error) {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+
+	ru.lastRoomId++
+	r.ID = ru.lastRoomId
+	r.CreatedAt = now()
+	r.UpdatedAt = r.CreatedAt
+	ru.rooms[r.ID] = r
+
+	ru.members[r.ID] = make(map[int64]roomMember)
+	ru.addMembers(r.ID, memberIds)
+
+	return r.ID, nil
+}
+
+func (ru *memRoomRepository) ListMembers(idRoom int64) ([]models.RoomMember, error) {
+	ru.mu.RLock()
+	defer ru.mu.RUnlock()
+
+	type joined struct {
+		member models.RoomMember
+		at     time.Time
+	}
+
+	var list []joined
+	for id, member := range ru.members[idRoom] {
+		u := ru.users[id]
+		list = append(list, joined{models.RoomMember{ID: u.ID, Username: u.Username, Phone: u.Phone}, member.joinedAt})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].at.Equal(list[j].at) {
+			return list[i].at.Before(list[j].at)
+		}
+		return list[i].member.ID < list[j].member.ID
+	})
+
+	var members []models.RoomMember
+	for _, j := range list {
+		members = append(members, j.member)
+	}
+	return members, nil
+}
+
+func (ru *memRoomRepository) IsMember(idRoom int64, idUser int64) (bool, error) {
+	ru.mu.RLock()
+	defer ru.mu.RUnlock()
+
+	_, ok := ru.members[idRoom][idUser]
+	return ok, nil
+}
+
+func (ru *memRoomRepository) AddMembers(idRoom int64, memberIds []int64) error {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+
+	if _, ok := ru.rooms[idRoom]; !ok {
+		return sql.ErrNoRows
+	}
+	ru.addMembers(idRoom, memberIds)
+	return nil
+}
+
+// addMembers expects the lock to be held, existing members are left untouched
+func (ru *memRoomRepository) addMembers(idRoom int64, memberIds []int64) {
+	at := now()
+	for _, id := range memberIds {
+		if _, ok := ru.members[idRoom][id]; !ok {
+			ru.members[idRoom][id] = roomMember{joinedAt: at}
+		}
+	}
+}
+
+func (ru *memRoomRepository) RemoveMember(idRoom int64, idUser int64) (int64, error) {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+
+	if _, ok := ru.members[idRoom][idUser]; !ok {
+		return 0, nil
+	}
+	delete(ru.members[idRoom], idUser)
+
+	// the member who joined first takes over, none when the group is empty
+	if r := ru.rooms[idRoom]; r.IdCreator == idUser {
+		r.IdCreator = 0
+		var first roomMember
+		for id, member := range ru.members[idRoom] {
+			if r.IdCreator == 0 || member.joinedAt.Before(first.joinedAt) || (member.joinedAt.Equal(first.joinedAt) && id < r.IdCreator) {
+				r.IdCreator, first = id, member
+			}
+		}
+		ru.rooms[idRoom] = r
+	}
+	return 1, nil
+}
+
+func (ru *memRoomRepository) UpdateLastMsg(idRoom int64, idChanged int64, idLastMsg int64, msg string) (int64, error) {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+
+	r, ok := ru.rooms[idRoom]
+	if !ok || r.IdLastMsg > idChanged {
+		return 0, nil
+	}
+	r.LastMsg = msg
+	r.IdLastMsg = idLastMsg
+	r.UpdatedAt = now()
+	ru.rooms[idRoom] = r
+
+	return 1, nil
+}
+
+// unreadCount expects the lock to be held, own, deleted and hidden messages
+// are never unread
+func (ru *memRoomRepository) unreadCount(idRoom int64, idUser int64) int64 {
+	lastRead := ru.members[idRoom][idUser].lastRead
+
+	var count int64
+	for _, chat := range ru.messages {
+		if chat.IdRoom == idRoom && chat.ID > lastRead && chat.IdSender != idUser && !chat.Deleted && !ru.hidden[chat.ID][idUser] {
+			count++
+		}
+	}
+	return count
+}
+
+func (ru *memRoomRepository) MarkRead(idRoom int64, idUser int64, upTo int64) (int64, error) {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+
+	member, ok := ru.members[idRoom][idUser]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+
+	// the cursor only moves to messages of the room, and never backwards
+	for _, chat := range ru.messages {
+		if chat.IdRoom == idRoom && (upTo == 0 || chat.ID <= upTo) && chat.ID > member.lastRead {
+			member.lastRead = chat.ID
+		}
+	}
+	// a read message was delivered as well
+	if member.lastDelivered < member.lastRead {
+		member.lastDelivered = member.lastRead
+	}
+	ru.members[idRoom][idUser] = member
+
+	return member.lastRead, nil
+}
+
+func (ru *memRoomRepository) MarkDelivered(idRoom int64, idUser int64, idMessage int64) (bool, error) {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+
+	member, ok := ru.members[idRoom][idUser]
+	if !ok || member.lastDelivered >= idMessage {
+		return false, nil
+	}
+	member.lastDelivered = idMessage
+	ru.members[idRoom][idUser] = member
+
+	return true, nil
+}
+
+func (ru *memRoomRepository) ListReadCursors(idRoom int64) ([]models.ReadCursor, error) {
+	ru.mu.RLock()
+	defer ru.mu.RUnlock()
+
+	var cursors []models.ReadCursor
+	for id, member := range ru.members[idRoom] {
+		cursors = append(cursors, models.ReadCursor{
+			IdRoom:                 idRoom,
+			IdUser:                 id,
+			LastDeliveredMessageId: member.lastDelivered,
+			LastReadMessageId:      member.lastRead,
+		})
+	}
+	sort.Slice(cursors, func(i, j int) bool { return cursors[i].IdUser < cursors[j].IdUser })
+
+	return cursors, nil
+}
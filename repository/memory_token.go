@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/f-chilmi/just-text-go/models"
+)
+
+type memTokenRepository struct {
+	*memoryDB
+}
+
+func (t *memTokenRepository) InsertRefreshToken(token models.RefreshToken) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.insertRefreshToken(token), nil
+}
+
+// insertRefreshToken expects the lock to be held
+func (t *memTokenRepository) insertRefreshToken(token models.RefreshToken) int64 {
+	t.lastTokenId++
+	token.ID = t.lastTokenId
+	token.CreatedAt = now()
+	t.refreshTokens[token.ID] = token
+
+	return token.ID
+}
+
+func (t *memTokenRepository) GetRefreshToken(tokenHash string) (models.RefreshToken, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, token := range t.refreshTokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return models.RefreshToken{}, sql.ErrNoRows
+}
+
+func (t *memTokenRepository) RotateRefreshToken(idOld int64, token models.RefreshToken) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	old, ok := t.refreshTokens[idOld]
+	if !ok || old.RevokedAt.Valid {
+		return 0, sql.ErrNoRows
+	}
+
+	id := t.insertRefreshToken(token)
+	old.RevokedAt = sql.NullTime{Time: now(), Valid: true}
+	old.ReplacedBy = id
+	t.refreshTokens[idOld] = old
+
+	return id, nil
+}
+
+func (t *memTokenRepository) RevokeFamily(family string) error {
+	t.revokeRefreshTokens(func(token models.RefreshToken) bool { return token.Family == family })
+	return nil
+}
+
+func (t *memTokenRepository) RevokeUserRefreshTokens(idUser int64) error {
+	t.revokeRefreshTokens(func(token models.RefreshToken) bool { return token.IdUser == idUser })
+	return nil
+}
+
+func (t *memTokenRepository) revokeRefreshTokens(match func(models.RefreshToken) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	at := now()
+	for id, token := range t.refreshTokens {
+		if match(token) && !token.RevokedAt.Valid {
+			token.RevokedAt = sql.NullTime{Time: at, Valid: true}
+			t.refreshTokens[id] = token
+		}
+	}
+}
+
+func (t *memTokenRepository) RevokeToken(token models.RevokedToken) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.revokedTokens[token.Jti]; !ok {
+		token.CreatedAt = now()
+		t.revokedTokens[token.Jti] = token
+	}
+	return nil
+}
+
+func (t *memTokenRepository) RevokeUserTokens(idUser int64, before time.Time, expiresAt time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.userRevocations[idUser] = userRevocation{revokedBefore: before, expiresAt: expiresAt}
+	return nil
+}
+
+func (t *memTokenRepository) IsRevoked(jti string, idUser int64, issuedAt time.Time) (bool, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if _, ok := t.revokedTokens[jti]; ok {
+		return true, nil
+	}
+	rev, ok := t.userRevocations[idUser]
+	return ok && !rev.revokedBefore.Before(issuedAt), nil
+}
+
+func (t *memTokenRepository) DeleteExpired(at time.Time) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var deleted int64
+	for jti, token := range t.revokedTokens {
+		if token.ExpiresAt.Before(at) {
+			delete(t.revokedTokens, jti)
+			deleted++
+		}
+	}
+	for id, rev := range t.userRevocations {
+		if rev.expiresAt.Before(at) {
+			delete(t.userRevocations, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/f-chilmi/just-text-go/models"
+)
+
+type memUserRepository struct {
+	*memoryDB
+}
+
+// errPhoneTaken stands for the unique constraint on users.phone
+var errPhoneTaken = errors.New("phone already registered")
+
+func (u *memUserRepository) InsertUser(user models.User) (int64, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, err := u.userByPhone(user.Phone); err == nil {
+		return 0, errPhoneTaken
+	}
+
+	u.lastUserId++
+	user.ID = u.lastUserId
+	user.CreatedAt = now()
+	user.UpdatedAt = user.CreatedAt
+	u.users[user.ID] = user
+
+	return user.ID, nil
+}
+
+func (u *memUserRepository) GetUsers(query models.UserQuery) ([]models.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	prefix := strings.ToLower(query.Q)
+
+	var users []models.User
+	for _, user := range u.users {
+		if strings.HasPrefix(strings.ToLower(user.Username), prefix) || strings.HasPrefix(user.Phone, prefix) {
+			users = append(users, user)
+		}
+	}
+
+	// same order as the postgres one, ties broken by id
+	sort.Slice(users, func(i, j int) bool {
+		a, b := users[i], users[j]
+		if query.Desc {
+			a, b = b, a
+		}
+		if query.Sort == "created_at" {
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		} else if la, lb := strings.ToLower(a.Username), strings.ToLower(b.Username); la != lb {
+			return la < lb
+		}
+		return a.ID < b.ID
+	})
+
+	if query.Offset >= len(users) {
+		return nil, nil
+	}
+	users = users[query.Offset:]
+	if len(users) > query.Limit {
+		users = users[:query.Limit]
+	}
+	return users, nil
+}
+
+func (u *memUserRepository) GetUser(id int64) (models.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	user, ok := u.users[id]
+	if !ok {
+		return models.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (u *memUserRepository) UpdateUser(id int64, user models.User) (int64, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	existing, ok := u.users[id]
+	if !ok {
+		return 0, nil
+	}
+	if other, err := u.userByPhone(user.Phone); err == nil && other.ID != id {
+		return 0, errPhoneTaken
+	}
+
+	existing.Username = user.Username
+	existing.Phone = user.Phone
+	existing.UpdatedAt = now()
+	u.users[id] = existing
+
+	return 1, nil
+}
+
+func (u *memUserRepository) GetUserByPhone(phone string) (models.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	return u.userByPhone(phone)
+}
+
+// userByPhone expects the lock to be held
+func (m *memoryDB) userByPhone(phone string) (models.User, error) {
+	for _, user := range m.users {
+		if user.Phone == phone {
+			return user, nil
+		}
+	}
+	return models.User{}, sql.ErrNoRows
+}
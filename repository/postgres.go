@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"database/sql"
+
+	_ "github.com/lib/pq"
+)
+
+// NewPostgresStore returns the repositories backed by the shared pool.
+func NewPostgresStore(db *sql.DB) *Store {
+	return &Store{
+		Users:       &pgUserRepository{db: db},
+		Rooms:       &pgRoomRepository{db: db},
+		Messages:    &pgMessageRepository{db: db},
+		Attachments: &pgAttachmentRepository{db: db},
+		Tokens:      &pgTokenRepository{db: db},
+	}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// nullableId maps the zero id to NULL for nullable foreign keys
+func nullableId(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/f-chilmi/just-text-go/models"
+	"github.com/lib/pq"
+)
+
+type pgAttachmentRepository struct {
+	db *sql.DB
+}
+
+const attachmentColumns = `id, id_room, id_uploader, COALESCE(id_message, 0), file_name, mime_type, size, checksum, storage_key,
+	COALESCE(width, 0), COALESCE(height, 0), COALESCE(thumbnail_status, ''), COALESCE(thumbnail_key, ''), created_at`
+
+func scanAttachment(row rowScanner) (models.Attachment, error) {
+	var a models.Attachment
+	err := row.Scan(&a.ID, &a.IdRoom, &a.IdUploader, &a.IdMessage, &a.FileName, &a.MimeType, &a.Size, &a.Checksum, &a.StorageKey,
+		&a.Width, &a.Height, &a.ThumbnailStatus, &a.ThumbnailKey, &a.CreatedAt)
+	return a, err
+}
+
+func (ar *pgAttachmentRepository) InsertAttachment(a models.Attachment) (models.Attachment, error) {
+	// create the insert query
+	// returning the columns will return the inserted attachment
+	sqlStatement := `
+		INSERT INTO attachments (id_room, id_uploader, file_name, mime_type, size, checksum, storage_key, thumbnail_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')) RETURNING ` + attachmentColumns
+
+	// execute the sql statement
+	row := ar.db.QueryRow(sqlStatement, a.IdRoom, a.IdUploader, a.FileName, a.MimeType, a.Size, a.Checksum, a.StorageKey, a.ThumbnailStatus)
+
+	return scanAttachment(row)
+}
+
+func (ar *pgAttachmentRepository) GetAttachment(id int64) (models.Attachment, error) {
+	// create the select sql query
+	sqlStatement := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id=$1`
+
+	// execute the sql statement
+	row := ar.db.QueryRow(sqlStatement, id)
+
+	return scanAttachment(row)
+}
+
+func (ar *pgAttachmentRepository) ListAttachments(idMessages []int64) (map[int64][]models.Attachment, error) {
+	attachments := make(map[int64][]models.Attachment)
+
+	// create the select sql query
+	sqlStatement := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id_message = ANY($1) ORDER BY id`
+
+	// execute the sql statement
+	rows, err := ar.db.Query(sqlStatement, pq.Array(idMessages))
+	if err != nil {
+		return attachments, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return attachments, err
+		}
+
+		attachments[a.IdMessage] = append(attachments[a.IdMessage], a)
+	}
+
+	return attachments, rows.Err()
+}
+
+func (ar *pgAttachmentRepository) DeleteMsgAttachments(idMessage int64) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+
+	// returning the columns will return the deleted attachments
+	sqlStatement := `DELETE FROM attachments WHERE id_message=$1 RETURNING ` + attachmentColumns
+
+	// execute the sql statement
+	rows, err := ar.db.Query(sqlStatement, idMessage)
+	if err != nil {
+		return attachments, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return attachments, err
+		}
+
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+func (ar *pgAttachmentRepository) ListPendingThumbnails(limit int) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+
+	// create the select sql query
+	sqlStatement := `SELECT ` + attachmentColumns + ` FROM attachments WHERE thumbnail_status = 'pending' ORDER BY id LIMIT $1`
+
+	// execute the sql statement
+	rows, err := ar.db.Query(sqlStatement, limit)
+	if err != nil {
+		return attachments, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return attachments, err
+		}
+
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+func (ar *pgAttachmentRepository) SetThumbnail(id int64, width int, height int, thumbnailKey string, status string) (int64, error) {
+	// create the update sql query
+	sqlStatement := `
+		UPDATE attachments SET width=NULLIF($2, 0), height=NULLIF($3, 0), thumbnail_key=NULLIF($4, ''), thumbnail_status=$5
+		WHERE id=$1`
+
+	// execute the sql statement
+	res, err := ar.db.Exec(sqlStatement, id, width, height, thumbnailKey, status)
+	if err != nil {
+		return 0, err
+	}
+
+	// check how many rows affected
+	return res.RowsAffected()
+}
@@ -0,0 +1,432 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/f-chilmi/just-text-go/models"
+	"github.com/lib/pq"
+)
+
+type pgMessageRepository struct {
+	db *sql.DB
+}
+
+// messageColumns is the column list matching scanMessage, id_recipient is
+// NULL for messages sent into a group room.
+const messageColumns = `messages.id, messages.id_sender, COALESCE(messages.id_recipient, 0), messages.id_room, messages.content, messages.edited, messages.deleted_at IS NOT NULL, COALESCE(messages.reply_to_id, 0), messages.created_at, messages.updated_at`
+
+func scanMessage(row rowScanner) (models.Message, error) {
+	var m models.Message
+	err := row.Scan(&m.ID, &m.IdSender, &m.IdRecipient, &m.IdRoom, &m.Content, &m.Edited, &m.Deleted, &m.ReplyToId, &m.CreatedAt, &m.UpdatedAt)
+	return m, err
+}
+
+func (m *pgMessageRepository) SendMsg(message models.Message, attachmentIds []int64) (models.Message, error) {
+	// the message, its attachments, the room preview and the cursors of the
+	// sender change together
+	tx, err := m.db.Begin()
+	if err != nil {
+		return models.Message{}, err
+	}
+	defer tx.Rollback()
+
+	// create the insert query
+	// returning the columns will return the inserted message
+	sqlStatement := `INSERT INTO messages (id_sender, id_recipient, id_room, content, reply_to_id) VALUES ($1, $2, $3, $4, $5) RETURNING ` + messageColumns + `;`
+
+	// execute the sql statement
+	// scan function will save the inserted message
+	row := tx.QueryRow(sqlStatement, message.IdSender, nullableId(message.IdRecipient), message.IdRoom, message.Content, nullableId(message.ReplyToId))
+	message, err = scanMessage(row)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	// only attachments uploaded by the sender into the room and not sent yet
+	if len(attachmentIds) > 0 {
+		sqlAttach := `
+			UPDATE attachments SET id_message=$2
+			WHERE id = ANY($1) AND id_uploader=$3 AND id_room=$4 AND id_message IS NULL`
+
+		res, err := tx.Exec(sqlAttach, pq.Array(attachmentIds), message.ID, message.IdSender, message.IdRoom)
+		if err != nil {
+			return models.Message{}, err
+		}
+		linked, err := res.RowsAffected()
+		if err != nil {
+			return models.Message{}, err
+		}
+		if linked != int64(len(attachmentIds)) {
+			return models.Message{}, ErrAttachmentTaken
+		}
+	}
+
+	// a concurrent send that committed a later message keeps the preview
+	sqlRoom := `
+		UPDATE rooms SET last_msg=$2, id_last_msg=$3, updated_at=$4
+		WHERE id=$1 AND (id_last_msg IS NULL OR id_last_msg < $3)`
+	if _, err = tx.Exec(sqlRoom, message.IdRoom, message.Preview(), message.ID, message.CreatedAt); err != nil {
+		return models.Message{}, err
+	}
+
+	// the sender has seen the room up to their own message
+	sqlCursor := `
+		UPDATE room_members SET
+			last_read_message_id = GREATEST(last_read_message_id, $3),
+			last_delivered_message_id = GREATEST(last_delivered_message_id, $3)
+		WHERE id_room=$1 AND id_user=$2`
+	if _, err = tx.Exec(sqlCursor, message.IdRoom, message.IdSender, message.ID); err != nil {
+		return models.Message{}, err
+	}
+
+	return message, tx.Commit()
+}
+
+func (m *pgMessageRepository) GetMsg(id int64) (models.Message, error) {
+	// create the select sql query
+	sqlStatement := `SELECT ` + messageColumns + ` FROM messages WHERE id=$1`
+
+	// execute the sql statement
+	row := m.db.QueryRow(sqlStatement, id)
+
+	return scanMessage(row)
+}
+
+func (m *pgMessageRepository) MsgSentWithin(id int64, window time.Duration) (bool, error) {
+	// created_at has no time zone, it is compared with the local time of
+	// the session that stamped it
+	sqlStatement := `SELECT created_at > LOCALTIMESTAMP - make_interval(secs => $2) FROM messages WHERE id=$1`
+
+	var within bool
+
+	// execute the sql statement
+	err := m.db.QueryRow(sqlStatement, id, window.Seconds()).Scan(&within)
+
+	return within, err
+}
+
+func (m *pgMessageRepository) ListQuotes(ids []int64) (map[int64]models.Quote, error) {
+	quotes := make(map[int64]models.Quote)
+
+	// create the select sql query
+	sqlStatement := `
+		SELECT messages.id, messages.id_sender, users.username, messages.content, messages.deleted_at IS NOT NULL from messages
+		INNER JOIN users on messages.id_sender = users.id
+		WHERE messages.id = ANY($1)`
+
+	// execute the sql statement
+	rows, err := m.db.Query(sqlStatement, pq.Array(ids))
+	if err != nil {
+		return quotes, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		var quote models.Quote
+
+		err = rows.Scan(&quote.ID, &quote.IdSender, &quote.Username, &quote.Content, &quote.Deleted)
+		if err != nil {
+			return quotes, err
+		}
+
+		quotes[quote.ID] = quote
+	}
+
+	return quotes, rows.Err()
+}
+
+func (m *pgMessageRepository) AddReaction(id int64, idUser int64, emoji string) error {
+	sqlStatement := `INSERT INTO message_reactions (id_message, id_user, emoji) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+
+	// execute the sql statement
+	_, err := m.db.Exec(sqlStatement, id, idUser, emoji)
+
+	return err
+}
+
+func (m *pgMessageRepository) RemoveReaction(id int64, idUser int64, emoji string) (int64, error) {
+	sqlStatement := `DELETE FROM message_reactions WHERE id_message=$1 AND id_user=$2 AND emoji=$3`
+
+	// execute the sql statement
+	res, err := m.db.Exec(sqlStatement, id, idUser, emoji)
+	if err != nil {
+		return 0, err
+	}
+
+	// check how many rows affected
+	return res.RowsAffected()
+}
+
+func (m *pgMessageRepository) ListReactions(ids []int64, idUser int64) (map[int64][]models.Reaction, error) {
+	reactions := make(map[int64][]models.Reaction)
+
+	// create the select sql query
+	sqlStatement := `
+		SELECT id_message, emoji, COUNT(*), BOOL_OR(id_user = $2) from message_reactions
+		WHERE id_message = ANY($1)
+		GROUP BY id_message, emoji
+		ORDER BY id_message, MIN(created_at), emoji`
+
+	// execute the sql statement
+	rows, err := m.db.Query(sqlStatement, pq.Array(ids), idUser)
+	if err != nil {
+		return reactions, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		var id int64
+		var reaction models.Reaction
+
+		err = rows.Scan(&id, &reaction.Emoji, &reaction.Count, &reaction.Me)
+		if err != nil {
+			return reactions, err
+		}
+
+		reactions[id] = append(reactions[id], reaction)
+	}
+
+	return reactions, rows.Err()
+}
+
+func (m *pgMessageRepository) EditMsg(id int64, content string) (models.Message, error) {
+	// the previous version and the edit are saved together
+	tx, err := m.db.Begin()
+	if err != nil {
+		return models.Message{}, err
+	}
+	defer tx.Rollback()
+
+	sqlStatement := `INSERT INTO message_edits (id_message, content) SELECT id, content FROM messages WHERE id=$1`
+	if _, err = tx.Exec(sqlStatement, id); err != nil {
+		return models.Message{}, err
+	}
+
+	// create the update sql query
+	// returning the columns will return the edited message
+	sqlStatement = `UPDATE messages SET content=$2, edited=true, updated_at=CURRENT_TIMESTAMP WHERE id=$1 RETURNING ` + messageColumns
+
+	message, err := scanMessage(tx.QueryRow(sqlStatement, id, content))
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	return message, tx.Commit()
+}
+
+func (m *pgMessageRepository) ListMsgEdits(id int64) ([]models.MessageEdit, error) {
+	var edits []models.MessageEdit
+
+	// create the select sql query
+	sqlStatement := `SELECT id, id_message, content, edited_at FROM message_edits WHERE id_message=$1 ORDER BY id`
+
+	// execute the sql statement
+	rows, err := m.db.Query(sqlStatement, id)
+	if err != nil {
+		return edits, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		var edit models.MessageEdit
+
+		err = rows.Scan(&edit.ID, &edit.IdMessage, &edit.Content, &edit.EditedAt)
+		if err != nil {
+			return edits, err
+		}
+
+		edits = append(edits, edit)
+	}
+
+	return edits, rows.Err()
+}
+
+func (m *pgMessageRepository) DeleteMsg(id int64) (models.Message, error) {
+	// the tombstone and the dropped history go together
+	tx, err := m.db.Begin()
+	if err != nil {
+		return models.Message{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM message_edits WHERE id_message=$1`, id); err != nil {
+		return models.Message{}, err
+	}
+	if _, err = tx.Exec(`DELETE FROM message_reactions WHERE id_message=$1`, id); err != nil {
+		return models.Message{}, err
+	}
+
+	// create the update sql query
+	// returning the columns will return the tombstone
+	sqlStatement := `UPDATE messages SET content='', deleted_at=CURRENT_TIMESTAMP, updated_at=CURRENT_TIMESTAMP WHERE id=$1 RETURNING ` + messageColumns
+
+	message, err := scanMessage(tx.QueryRow(sqlStatement, id))
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	return message, tx.Commit()
+}
+
+func (m *pgMessageRepository) HideMsg(id int64, idUser int64) error {
+	// hiding twice is not an error
+	sqlStatement := `INSERT INTO message_hidden (id_message, id_user) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	// execute the sql statement
+	_, err := m.db.Exec(sqlStatement, id, idUser)
+
+	return err
+}
+
+func (m *pgMessageRepository) LatestMsg(idRoom int64) (models.Message, error) {
+	// create the select sql query
+	sqlStatement := `SELECT ` + messageColumns + ` FROM messages WHERE id_room=$1 AND deleted_at IS NULL ORDER BY id DESC LIMIT 1`
+
+	// execute the sql statement
+	row := m.db.QueryRow(sqlStatement, idRoom)
+
+	return scanMessage(row)
+}
+
+func (m *pgMessageRepository) ListMsgSince(idUser int64, lastId int64, limit int) ([]models.Message, error) {
+	var chats []models.Message
+
+	// only messages of the rooms the user takes part in
+	sqlStatement := `
+		SELECT ` + messageColumns + ` from messages 
+		INNER JOIN room_members m on messages.id_room = m.id_room
+		WHERE m.id_user=$1 AND messages.id > $2
+		AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.id_message = messages.id AND h.id_user = $1)
+		ORDER BY messages.id
+		LIMIT $3`
+
+	// execute the sql statement
+	rows, err := m.db.Query(sqlStatement, idUser, lastId, limit)
+	if err != nil {
+		return chats, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		chat, err := scanMessage(rows)
+		if err != nil {
+			return chats, err
+		}
+
+		chats = append(chats, chat)
+	}
+
+	return chats, rows.Err()
+}
+
+func (m *pgMessageRepository) OpenRoomChat(idRoom int64, idUser int64, before int64, after int64, limit int) ([]models.Message, error) {
+	var chats []models.Message
+
+	// create the select sql query
+	// going backwards the latest messages are picked, then put back in order
+	sqlStatement := `SELECT ` + messageColumns + ` FROM messages
+		WHERE id_room=$1
+		AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.id_message = messages.id AND h.id_user = $2)`
+	args := []interface{}{idRoom, idUser, limit}
+	if after > 0 {
+		sqlStatement += ` AND id > $4 ORDER BY id LIMIT $3`
+		args = append(args, after)
+	} else if before > 0 {
+		sqlStatement += ` AND id < $4 ORDER BY id DESC LIMIT $3`
+		args = append(args, before)
+	} else {
+		sqlStatement += ` ORDER BY id DESC LIMIT $3`
+	}
+
+	// execute the sql statement
+	rows, err := m.db.Query(sqlStatement, args...)
+	if err != nil {
+		return chats, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		// unmarshal the row object to message
+		chat, err := scanMessage(rows)
+		if err != nil {
+			return chats, err
+		}
+
+		// append the message in the chats slice
+		chats = append(chats, chat)
+
+	}
+	if err := rows.Err(); err != nil {
+		return chats, err
+	}
+
+	if after <= 0 {
+		for i, j := 0, len(chats)-1; i < j; i, j = i+1, j-1 {
+			chats[i], chats[j] = chats[j], chats[i]
+		}
+	}
+
+	return chats, nil
+}
+
+func (m *pgMessageRepository) SearchMsg(idUser int64, q string, before int64, limit int) ([]models.SearchHit, error) {
+	var hits []models.SearchHit
+
+	// the content is html escaped before ts_headline marks the matches
+	sqlStatement := `
+		SELECT messages.id, messages.id_room, rooms.name, messages.id_sender, users.username,
+		ts_headline('simple', replace(replace(replace(messages.content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), query,
+			'StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=8, MaxFragments=1'),
+		messages.created_at
+		FROM messages
+		INNER JOIN room_members m ON m.id_room = messages.id_room AND m.id_user = $1
+		INNER JOIN rooms ON rooms.id = messages.id_room
+		INNER JOIN users ON users.id = messages.id_sender
+		CROSS JOIN websearch_to_tsquery('simple', $2) query
+		WHERE messages.search @@ query AND messages.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.id_message = messages.id AND h.id_user = $1)`
+	args := []interface{}{idUser, q, limit}
+	if before > 0 {
+		sqlStatement += ` AND messages.id < $4`
+		args = append(args, before)
+	}
+	sqlStatement += ` ORDER BY messages.id DESC LIMIT $3`
+
+	// execute the sql statement
+	rows, err := m.db.Query(sqlStatement, args...)
+	if err != nil {
+		return hits, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		var hit models.SearchHit
+		err := rows.Scan(&hit.IdMessage, &hit.IdRoom, &hit.RoomName, &hit.IdSender, &hit.SenderUsername, &hit.Snippet, &hit.CreatedAt)
+		if err != nil {
+			return hits, err
+		}
+
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
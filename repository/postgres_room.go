@@ -0,0 +1,401 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/f-chilmi/just-text-go/models"
+)
+
+type pgRoomRepository struct {
+	db *sql.DB
+}
+
+// selectRoomList is shared by every query returning a RoomList, the where
+// clause (and extra joins) are appended by the caller.
+const selectRoomList = `
+	SELECT 
+		rooms.id, 
+		rooms.name, 
+		rooms.is_group, 
+		COALESCE(rooms.id_creator, 0), 
+		COALESCE(id_user1, 0), 
+		COALESCE(a.username, '') as username1, 
+		COALESCE(a.phone, '') as phone1, 
+		COALESCE(id_user2, 0), 
+		COALESCE(b.username, '') as username2, 
+		COALESCE(b.phone, '') as phone2, 
+		COALESCE(id_last_msg, 0), 
+		last_msg, 
+		rooms.created_at, 
+		rooms.updated_at from rooms 
+	LEFT JOIN users a on rooms.id_user1 = a.id
+	LEFT JOIN users b on rooms.id_user2 = b.id`
+
+func scanRoomList(row rowScanner) (models.RoomList, error) {
+	var room models.RoomList
+	err := row.Scan(
+		&room.ID,
+		&room.Name,
+		&room.IsGroup,
+		&room.IdCreator,
+		&room.IdUser1,
+		&room.Username1,
+		&room.Phone1,
+		&room.IdUser2,
+		&room.Username2,
+		&room.Phone2,
+		&room.IdLastMsg,
+		&room.LastMsg,
+		&room.CreatedAt,
+		&room.UpdatedAt,
+	)
+	return room, err
+}
+
+func (ru *pgRoomRepository) FindRoom(myId int64, idUser2 int64) (models.Room, error) {
+	// only 1:1 rooms, a group with the same two users is a different room
+	sqlStatement := selectRoomList + `
+	WHERE NOT rooms.is_group AND ((id_user1=$1 AND id_user2=$2) OR (id_user1=$2 AND id_user2=$1))`
+
+	// execute the sql statement
+	room, err := scanRoomList(ru.db.QueryRow(sqlStatement, myId, idUser2))
+	if err != nil {
+		return models.Room{}, err
+	}
+
+	return room.ToRoom(myId), err
+}
+
+func (ru *pgRoomRepository) FindRoomById(id int64) (models.RoomList, error) {
+	// create the select query
+	sqlStatement := selectRoomList + `
+	WHERE rooms.id=$1`
+
+	// execute the sql statement
+	return scanRoomList(ru.db.QueryRow(sqlStatement, id))
+}
+
+func (ru *pgRoomRepository) ListMembers(idRoom int64) ([]models.RoomMember, error) {
+	var members []models.RoomMember
+
+	sqlStatement := `
+		SELECT users.id, users.username, users.phone from room_members 
+		INNER JOIN users on room_members.id_user = users.id
+		WHERE room_members.id_room=$1
+		ORDER BY room_members.created_at, users.id`
+
+	// execute the sql statement
+	rows, err := ru.db.Query(sqlStatement, idRoom)
+	if err != nil {
+		return members, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		var member models.RoomMember
+
+		err = rows.Scan(&member.ID, &member.Username, &member.Phone)
+		if err != nil {
+			return members, err
+		}
+
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+func (ru *pgRoomRepository) ListRoomByToken(idUser int64) ([]models.Room, error) {
+	var rooms []models.Room
+
+	// every room (1:1 or group) the user is a member of
+	sqlStatement := selectRoomList + `
+	INNER JOIN room_members m on m.id_room = rooms.id
+	WHERE m.id_user=$1`
+
+	// execute the sql statement
+	rows, err := ru.db.Query(sqlStatement, idUser)
+	if err != nil {
+		return rooms, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		// unmarshal the row object to room
+		room, err := scanRoomList(rows)
+		if err != nil {
+			return rooms, err
+		}
+
+		// append the room in the rooms slice
+		rooms = append(rooms, room.ToRoom(idUser))
+
+	}
+	if err := rows.Err(); err != nil {
+		return rooms, err
+	}
+
+	unread, err := ru.unreadCounts(idUser)
+	if err != nil {
+		return rooms, err
+	}
+	for i := range rooms {
+		rooms[i].UnreadCount = unread[rooms[i].ID]
+	}
+
+	return rooms, nil
+}
+
+// unreadCounts returns the number of unread messages per room of the user,
+// rooms without any are left out. Own messages are never unread.
+func (ru *pgRoomRepository) unreadCounts(idUser int64) (map[int64]int64, error) {
+	counts := make(map[int64]int64)
+
+	sqlStatement := `
+		SELECT m.id_room, COUNT(messages.id) from room_members m
+		INNER JOIN messages on messages.id_room = m.id_room
+			AND messages.id > m.last_read_message_id
+			AND messages.id_sender <> m.id_user
+			AND messages.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.id_message = messages.id AND h.id_user = m.id_user)
+		WHERE m.id_user=$1
+		GROUP BY m.id_room`
+
+	// execute the sql statement
+	rows, err := ru.db.Query(sqlStatement, idUser)
+	if err != nil {
+		return counts, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	for rows.Next() {
+		var idRoom, count int64
+		if err := rows.Scan(&idRoom, &count); err != nil {
+			return counts, err
+		}
+		counts[idRoom] = count
+	}
+
+	return counts, rows.Err()
+}
+
+func (ru *pgRoomRepository) NewRoom(r models.RoomDb) (int64, error) {
+	return ru.insertRoom(r, []int64{r.IdUser1, r.IdUser2})
+}
+
+// NewGroup creates a group room, memberIds must include the creator.
+func (ru *pgRoomRepository) NewGroup(r models.RoomDb, memberIds []int64) (int64, error) {
+	r.IsGroup = true
+	r.IdUser1 = 0
+	r.IdUser2 = 0
+	return ru.insertRoom(r, memberIds)
+}
+
+func (ru *pgRoomRepository) insertRoom(r models.RoomDb, memberIds []int64) (int64, error) {
+	// the room and its members are created together
+	tx, err := ru.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// create the insert query
+	// returning id will return the id of the inserted room
+	sqlStatement := `INSERT INTO rooms (id_user1, id_user2, name, is_group, id_creator, last_msg) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;`
+
+	// inserted id will store in this id
+	var idRoom int64
+
+	// execute the sql statement
+	// scan function will save the inserted id in the id
+	err = tx.QueryRow(sqlStatement, nullableId(r.IdUser1), nullableId(r.IdUser2), r.Name, r.IsGroup, nullableId(r.IdCreator), r.LastMsg).Scan(&idRoom)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = addMembers(tx, idRoom, memberIds); err != nil {
+		return 0, err
+	}
+
+	// return the inserted room
+	return idRoom, tx.Commit()
+}
+
+func (ru *pgRoomRepository) AddMembers(idRoom int64, memberIds []int64) error {
+	tx, err := ru.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = addMembers(tx, idRoom, memberIds); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func addMembers(tx *sql.Tx, idRoom int64, memberIds []int64) error {
+	// already existing members are left untouched
+	sqlStatement := `INSERT INTO room_members (id_room, id_user) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	for _, id := range memberIds {
+		if _, err := tx.Exec(sqlStatement, idRoom, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ru *pgRoomRepository) RemoveMember(idRoom int64, idUser int64) (int64, error) {
+	// the member leaves and the group gets a new creator together
+	tx, err := ru.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	sqlStatement := `DELETE FROM room_members WHERE id_room=$1 AND id_user=$2`
+
+	// execute the sql statement
+	res, err := tx.Exec(sqlStatement, idRoom, idUser)
+	if err != nil {
+		return 0, err
+	}
+
+	// check how many rows affected
+	removed, err := res.RowsAffected()
+	if err != nil || removed == 0 {
+		return removed, err
+	}
+
+	// the member who joined first takes over, none when the group is empty
+	sqlCreator := `
+		UPDATE rooms SET id_creator = (
+			SELECT id_user FROM room_members WHERE id_room=$1
+			ORDER BY created_at, id_user LIMIT 1
+		)
+		WHERE id=$1 AND id_creator=$2`
+	if _, err = tx.Exec(sqlCreator, idRoom, idUser); err != nil {
+		return 0, err
+	}
+
+	return removed, tx.Commit()
+}
+
+func (ru *pgRoomRepository) UpdateLastMsg(idRoom int64, idChanged int64, idLastMsg int64, msg string) (int64, error) {
+	// a concurrent send that committed a later message keeps the preview
+	sqlStatement := `
+		UPDATE rooms SET last_msg=$4, id_last_msg=$3, updated_at=CURRENT_TIMESTAMP
+		WHERE id=$1 AND (id_last_msg IS NULL OR id_last_msg <= $2)`
+
+	// execute the sql statement
+	res, err := ru.db.Exec(sqlStatement, idRoom, idChanged, nullableId(idLastMsg), msg)
+	if err != nil {
+		return 0, err
+	}
+
+	// check how many rows affected
+	return res.RowsAffected()
+}
+
+func (ru *pgRoomRepository) IsMember(idRoom int64, idUser int64) (bool, error) {
+	sqlStatement := `SELECT EXISTS (SELECT 1 FROM room_members WHERE id_room=$1 AND id_user=$2)`
+
+	var member bool
+
+	// execute the sql statement
+	err := ru.db.QueryRow(sqlStatement, idRoom, idUser).Scan(&member)
+
+	return member, err
+}
+
+func (ru *pgRoomRepository) MarkRead(idRoom int64, idUser int64, upTo int64) (int64, error) {
+	// the cursor only moves to messages of the room, and never backwards
+	sqlStatement := `
+		UPDATE room_members SET last_read_message_id = GREATEST(last_read_message_id, (
+			SELECT COALESCE(MAX(id), 0) FROM messages WHERE id_room=$1 AND ($3 = 0 OR id <= $3)
+		))
+		WHERE id_room=$1 AND id_user=$2
+		RETURNING last_read_message_id`
+
+	// a read message was delivered as well
+	sqlDelivered := `
+		UPDATE room_members SET last_delivered_message_id = $3
+		WHERE id_room=$1 AND id_user=$2 AND last_delivered_message_id < $3`
+
+	tx, err := ru.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var lastRead int64
+
+	// execute the sql statement
+	err = tx.QueryRow(sqlStatement, idRoom, idUser, upTo).Scan(&lastRead)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.Exec(sqlDelivered, idRoom, idUser, lastRead); err != nil {
+		return 0, err
+	}
+
+	return lastRead, tx.Commit()
+}
+
+func (ru *pgRoomRepository) MarkDelivered(idRoom int64, idUser int64, idMessage int64) (bool, error) {
+	// the cursor never moves backwards
+	sqlStatement := `
+		UPDATE room_members SET last_delivered_message_id = $3
+		WHERE id_room=$1 AND id_user=$2 AND last_delivered_message_id < $3`
+
+	// execute the sql statement
+	res, err := ru.db.Exec(sqlStatement, idRoom, idUser, idMessage)
+	if err != nil {
+		return false, err
+	}
+
+	// check how many rows affected
+	moved, err := res.RowsAffected()
+
+	return moved > 0, err
+}
+
+func (ru *pgRoomRepository) ListReadCursors(idRoom int64) ([]models.ReadCursor, error) {
+	var cursors []models.ReadCursor
+
+	sqlStatement := `SELECT id_room, id_user, last_delivered_message_id, last_read_message_id FROM room_members WHERE id_room=$1 ORDER BY id_user`
+
+	// execute the sql statement
+	rows, err := ru.db.Query(sqlStatement, idRoom)
+	if err != nil {
+		return cursors, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		var cursor models.ReadCursor
+
+		err = rows.Scan(&cursor.IdRoom, &cursor.IdUser, &cursor.LastDeliveredMessageId, &cursor.LastReadMessageId)
+		if err != nil {
+			return cursors, err
+		}
+
+		cursors = append(cursors, cursor)
+	}
+
+	return cursors, rows.Err()
+}
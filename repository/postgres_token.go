@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/f-chilmi/just-text-go/models"
+)
+
+type pgTokenRepository struct {
+	db *sql.DB
+}
+
+func (t *pgTokenRepository) InsertRefreshToken(token models.RefreshToken) (int64, error) {
+	sqlStatement := `INSERT INTO refresh_tokens (id_user, family, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id;`
+
+	var id int64
+	err := t.db.QueryRow(sqlStatement, token.IdUser, token.Family, token.TokenHash, token.ExpiresAt).Scan(&id)
+
+	return id, err
+}
+
+func (t *pgTokenRepository) GetRefreshToken(tokenHash string) (models.RefreshToken, error) {
+	var token models.RefreshToken
+
+	// create the select sql query
+	sqlStatement := `SELECT id, id_user, family, token_hash, expires_at, revoked_at, COALESCE(replaced_by, 0), created_at FROM refresh_tokens WHERE token_hash=$1`
+
+	// execute the sql statement
+	row := t.db.QueryRow(sqlStatement, tokenHash)
+
+	err := row.Scan(&token.ID, &token.IdUser, &token.Family, &token.TokenHash, &token.ExpiresAt, &token.RevokedAt, &token.ReplacedBy, &token.CreatedAt)
+
+	// return empty token on error
+	return token, err
+}
+
+// RotateRefreshToken revokes the old token and stores its replacement in
+// one transaction. It fails with sql.ErrNoRows when the old token was
+// already rotated concurrently.
+func (t *pgTokenRepository) RotateRefreshToken(idOld int64, token models.RefreshToken) (int64, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	sqlStatement := `INSERT INTO refresh_tokens (id_user, family, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id;`
+	err = tx.QueryRow(sqlStatement, token.IdUser, token.Family, token.TokenHash, token.ExpiresAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	sqlStatement = `UPDATE refresh_tokens SET revoked_at=CURRENT_TIMESTAMP, replaced_by=$2 WHERE id=$1 AND revoked_at IS NULL`
+	res, err := tx.Exec(sqlStatement, idOld, id)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rowsAffected < 1 {
+		return 0, sql.ErrNoRows
+	}
+
+	return id, tx.Commit()
+}
+
+// RevokeFamily revokes every still valid token of the family, used when a
+// rotated token is presented again.
+func (t *pgTokenRepository) RevokeFamily(family string) error {
+	sqlStatement := `UPDATE refresh_tokens SET revoked_at=CURRENT_TIMESTAMP WHERE family=$1 AND revoked_at IS NULL`
+
+	_, err := t.db.Exec(sqlStatement, family)
+
+	return err
+}
+
+// RevokeUserRefreshTokens revokes every still valid token of the user.
+func (t *pgTokenRepository) RevokeUserRefreshTokens(idUser int64) error {
+	sqlStatement := `UPDATE refresh_tokens SET revoked_at=CURRENT_TIMESTAMP WHERE id_user=$1 AND revoked_at IS NULL`
+
+	_, err := t.db.Exec(sqlStatement, idUser)
+
+	return err
+}
+
+func (t *pgTokenRepository) RevokeToken(token models.RevokedToken) error {
+	sqlStatement := `INSERT INTO revoked_tokens (jti, id_user, expires_at) VALUES ($1, $2, $3) ON CONFLICT (jti) DO NOTHING`
+
+	_, err := t.db.Exec(sqlStatement, token.Jti, token.IdUser, token.ExpiresAt)
+
+	return err
+}
+
+// RevokeUserTokens revokes every token of the user issued up to before.
+func (t *pgTokenRepository) RevokeUserTokens(idUser int64, before time.Time, expiresAt time.Time) error {
+	sqlStatement := `
+		INSERT INTO user_revocations (id_user, revoked_before, expires_at) VALUES ($1, $2, $3) 
+		ON CONFLICT (id_user) DO UPDATE SET revoked_before=EXCLUDED.revoked_before, expires_at=EXCLUDED.expires_at`
+
+	_, err := t.db.Exec(sqlStatement, idUser, before, expiresAt)
+
+	return err
+}
+
+func (t *pgTokenRepository) IsRevoked(jti string, idUser int64, issuedAt time.Time) (bool, error) {
+	sqlStatement := `
+		SELECT 
+			EXISTS (SELECT 1 FROM revoked_tokens WHERE jti=$1) 
+			OR EXISTS (SELECT 1 FROM user_revocations WHERE id_user=$2 AND revoked_before >= $3)`
+
+	var revoked bool
+
+	// execute the sql statement
+	err := t.db.QueryRow(sqlStatement, jti, idUser, issuedAt).Scan(&revoked)
+
+	return revoked, err
+}
+
+func (t *pgTokenRepository) DeleteExpired(now time.Time) (int64, error) {
+	res, err := t.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err = t.db.Exec(`DELETE FROM user_revocations WHERE expires_at < $1`, now)
+	if err != nil {
+		return deleted, err
+	}
+	users, err := res.RowsAffected()
+
+	return deleted + users, err
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/f-chilmi/just-text-go/models"
+)
+
+type pgUserRepository struct {
+	db *sql.DB
+}
+
+const userColumns = `id, username, phone, password, created_at, updated_at`
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Username, &user.Phone, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	return user, err
+}
+
+func (u *pgUserRepository) InsertUser(user models.User) (int64, error) {
+	sqlStatement := `INSERT INTO users (username, phone, password) VALUES ($1, $2, $3) RETURNING id;`
+
+	var id int64
+	err := u.db.QueryRow(sqlStatement, user.Username, user.Phone, user.Password).Scan(&id)
+
+	return id, err
+}
+
+// likeEscaper escapes the LIKE wildcards of a search prefix.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (u *pgUserRepository) GetUsers(query models.UserQuery) ([]models.User, error) {
+	var users []models.User
+
+	// only known columns ever reach the order by
+	order := "lower(username)"
+	if query.Sort == "created_at" {
+		order = "created_at"
+	}
+	if query.Desc {
+		order += " DESC, id DESC"
+	} else {
+		order += ", id"
+	}
+
+	// create the select sql query
+	// an empty prefix matches everyone
+	sqlStatement := `
+		SELECT ` + userColumns + ` FROM users
+		WHERE lower(username) LIKE $1 OR phone LIKE $1
+		ORDER BY ` + order + `
+		LIMIT $2 OFFSET $3`
+
+	// execute the sql statement
+	prefix := likeEscaper.Replace(strings.ToLower(query.Q)) + "%"
+	rows, err := u.db.Query(sqlStatement, prefix, query.Limit, query.Offset)
+	if err != nil {
+		return users, err
+	}
+
+	// close the statement
+	defer rows.Close()
+
+	// iterate over the rows
+	for rows.Next() {
+		// unmarshal the row object to user
+		user, err := scanUser(rows)
+		if err != nil {
+			return users, err
+		}
+
+		// append the user in the users slice
+		users = append(users, user)
+
+	}
+
+	// return empty user on error
+	return users, rows.Err()
+}
+
+func (u *pgUserRepository) GetUser(id int64) (models.User, error) {
+	// create the select sql query
+	sqlStatement := `SELECT ` + userColumns + ` FROM users WHERE id=$1`
+
+	// execute the sql statement
+	row := u.db.QueryRow(sqlStatement, id)
+
+	// unmarshal the row object to user
+	return scanUser(row)
+}
+
+func (u *pgUserRepository) UpdateUser(id int64, user models.User) (int64, error) {
+	// create the update sql query
+	sqlStatement := `UPDATE users SET username=$2, phone=$3, updated_at=CURRENT_TIMESTAMP WHERE id=$1`
+
+	// execute the sql statement
+	res, err := u.db.Exec(sqlStatement, id, user.Username, user.Phone)
+	if err != nil {
+		return 0, err
+	}
+
+	// check how many rows affected
+	return res.RowsAffected()
+}
+
+func (u *pgUserRepository) GetUserByPhone(phone string) (models.User, error) {
+	// create the select sql query
+	sqlStatement := `SELECT ` + userColumns + ` FROM users WHERE phone=$1`
+
+	// execute the sql statement
+	row := u.db.QueryRow(sqlStatement, phone)
+
+	return scanUser(row)
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/f-chilmi/just-text-go/models"
+)
+
+// Lookups of a single row return sql.ErrNoRows when nothing matches,
+// whatever the backend, so the controllers can keep switching on it.
+
+// ErrAttachmentTaken is returned by SendMsg when one of the attachments
+// cannot be linked to the message, nothing is saved then.
+var ErrAttachmentTaken = errors.New("an attachment cannot be sent")
+
+type UserRepository interface {
+	InsertUser(user models.User) (int64, error)
+	// GetUsers returns the users matching the query, ordered by its sort
+	// then by id.
+	GetUsers(query models.UserQuery) ([]models.User, error)
+	GetUser(id int64) (models.User, error)
+	GetUserByPhone(phone string) (models.User, error)
+	UpdateUser(id int64, user models.User) (int64, error)
+}
+
+type RoomRepository interface {
+	FindRoom(myId int64, idUser2 int64) (models.Room, error)
+	FindRoomById(id int64) (models.RoomList, error)
+	ListRoomByToken(idUser int64) ([]models.Room, error)
+	NewRoom(r models.RoomDb) (int64, error)
+	NewGroup(r models.RoomDb, memberIds []int64) (int64, error)
+	ListMembers(idRoom int64) ([]models.RoomMember, error)
+	IsMember(idRoom int64, idUser int64) (bool, error)
+	AddMembers(idRoom int64, memberIds []int64) error
+	// RemoveMember returns the number of members removed. When the creator
+	// of a group leaves, the member who joined first takes over.
+	RemoveMember(idRoom int64, idUser int64) (int64, error)
+	// UpdateLastMsg sets the preview of the room once idChanged was edited
+	// or deleted, idLastMsg being 0 when there is no message left. The
+	// preview is left alone when it already moved past idChanged, it returns
+	// the number of rooms updated.
+	UpdateLastMsg(idRoom int64, idChanged int64, idLastMsg int64, msg string) (int64, error)
+	// MarkRead moves the read cursor of the member up to the latest message
+	// of the room not above upTo (any when 0) and returns it. It fails with
+	// sql.ErrNoRows when the user is not a member.
+	MarkRead(idRoom int64, idUser int64, upTo int64) (int64, error)
+	// MarkDelivered moves the delivery cursor of the member to idMessage,
+	// a message of the room. It returns false when it was already there.
+	MarkDelivered(idRoom int64, idUser int64, idMessage int64) (bool, error)
+	ListReadCursors(idRoom int64) ([]models.ReadCursor, error)
+}
+
+type MessageRepository interface {
+	// SendMsg inserts the message and, in the same transaction, links the
+	// attachments to it, makes it the last message of its room and moves
+	// the cursors of its sender to it.
+	SendMsg(message models.Message, attachmentIds []int64) (models.Message, error)
+	GetMsg(id int64) (models.Message, error)
+	// MsgSentWithin reports whether the message was sent less than window
+	// ago, measured by the database clock the message was stamped with.
+	MsgSentWithin(id int64, window time.Duration) (bool, error)
+	// ListQuotes returns the quotes of the given messages by id, with the
+	// full content, unknown ids are left out.
+	ListQuotes(ids []int64) (map[int64]models.Quote, error)
+	// EditMsg replaces the content of the message, keeping the previous one
+	// in its edit history, and returns the edited message.
+	EditMsg(id int64, content string) (models.Message, error)
+	ListMsgEdits(id int64) ([]models.MessageEdit, error)
+	// DeleteMsg turns the message into a tombstone for everyone, its content,
+	// edit history and reactions are dropped.
+	DeleteMsg(id int64) (models.Message, error)
+	// HideMsg deletes the message for idUser only.
+	HideMsg(id int64, idUser int64) error
+	// LatestMsg returns the latest message of the room not deleted for
+	// everyone, sql.ErrNoRows when there is none.
+	LatestMsg(idRoom int64) (models.Message, error)
+	// AddReaction adds the reaction of the user, reacting twice with the
+	// same emoji is not an error.
+	AddReaction(id int64, idUser int64, emoji string) error
+	RemoveReaction(id int64, idUser int64, emoji string) (int64, error)
+	// ListReactions returns the reactions of the given messages by id, each
+	// emoji in the order it was first used, Me being set for idUser.
+	ListReactions(ids []int64, idUser int64) (map[int64][]models.Reaction, error)
+	// OpenRoomChat returns up to limit messages of the room in id order,
+	// leaving out the ones idUser has hidden. With after set they are the
+	// first ones above after, otherwise the last ones below before (when
+	// set).
+	OpenRoomChat(idRoom int64, idUser int64, before int64, after int64, limit int) ([]models.Message, error)
+	ListMsgSince(idUser int64, lastId int64, limit int) ([]models.Message, error)
+	// SearchMsg returns up to limit messages matching q, newest first and
+	// below before when it is set, of the rooms idUser belongs to. Deleted
+	// and hidden messages are left out.
+	SearchMsg(idUser int64, q string, before int64, limit int) ([]models.SearchHit, error)
+}
+
+type AttachmentRepository interface {
+	InsertAttachment(a models.Attachment) (models.Attachment, error)
+	GetAttachment(id int64) (models.Attachment, error)
+	// ListAttachments returns the attachments of the given messages by id.
+	ListAttachments(idMessages []int64) (map[int64][]models.Attachment, error)
+	// DeleteMsgAttachments drops the attachments of the message and returns
+	// them so their content can be removed too.
+	DeleteMsgAttachments(idMessage int64) ([]models.Attachment, error)
+	// ListPendingThumbnails returns the oldest image attachments whose
+	// thumbnail is still to be made.
+	ListPendingThumbnails(limit int) ([]models.Attachment, error)
+	// SetThumbnail records the outcome of the thumbnail of an attachment.
+	// It returns 0 when the attachment no longer exists.
+	SetThumbnail(id int64, width int, height int, thumbnailKey string, status string) (int64, error)
+}
+
+type TokenRepository interface {
+	InsertRefreshToken(token models.RefreshToken) (int64, error)
+	GetRefreshToken(tokenHash string) (models.RefreshToken, error)
+	// RotateRefreshToken fails with sql.ErrNoRows when idOld was already rotated
+	RotateRefreshToken(idOld int64, token models.RefreshToken) (int64, error)
+	RevokeFamily(family string) error
+	RevokeUserRefreshTokens(idUser int64) error
+
+	RevokeToken(token models.RevokedToken) error
+	RevokeUserTokens(idUser int64, before time.Time, expiresAt time.Time) error
+	IsRevoked(jti string, idUser int64, issuedAt time.Time) (bool, error)
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+// Store groups the repositories of one storage backend.
+type Store struct {
+	Users       UserRepository
+	Rooms       RoomRepository
+	Messages    MessageRepository
+	Attachments AttachmentRepository
+	Tokens      TokenRepository
+}
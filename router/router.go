@@ -7,30 +7,61 @@ import (
 	"github.com/f-chilmi/just-text-go/middlewares"
 )
 
-func Router() *mux.Router {
+func Router(s *controllers.Server, m *middlewares.Middleware) *mux.Router {
 	router := mux.NewRouter()
 
 	// authentications
-	router.HandleFunc("/register", controllers.Register).Methods("POST", "OPTIONS")
-	router.HandleFunc("/login", controllers.Login).Methods("POST", "OPTIONS")
+	router.HandleFunc("/register", s.Register).Methods("POST", "OPTIONS")
+	router.HandleFunc("/login", s.Login).Methods("POST", "OPTIONS")
+	router.HandleFunc("/token/refresh", s.RefreshToken).Methods("POST", "OPTIONS")
+	router.HandleFunc("/logout", m.SetMiddlewareAuth(s.Logout)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/logout/all", m.SetMiddlewareAuth(s.LogoutAll)).Methods("POST", "OPTIONS")
 
 	// users
-	router.HandleFunc("/", middlewares.SetMiddlewareAuth(controllers.HomeController)).Methods("GET", "OPTIONS")
-	router.HandleFunc("/users", middlewares.SetMiddlewareAuth(controllers.FindAll)).Methods("GET", "OPTIONS")
-	router.HandleFunc("/user/{id}", middlewares.SetMiddlewareAuth(controllers.FindById)).Methods("GET", "OPTIONS")
-	router.HandleFunc("/user/{id}", middlewares.SetMiddlewareAuth(controllers.UpdateUser)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/", m.SetMiddlewareAuth(s.HomeController)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/users", m.SetMiddlewareAuth(s.FindAll)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/user/{id}", m.SetMiddlewareAuth(s.FindById)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/user/{id}", m.SetMiddlewareAuth(middlewares.SetMiddlewareOwner(s.UpdateUser))).Methods("PUT", "OPTIONS")
 
 	// find user by phone
-	router.HandleFunc("/phone/{phone}", middlewares.SetMiddlewareAuth(controllers.FindRoomByPhone)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/phone/{phone}", m.SetMiddlewareAuth(s.FindRoomByPhone)).Methods("GET", "OPTIONS")
 
 	// get rooms
 	// by token
-	router.HandleFunc("/room", middlewares.SetMiddlewareAuth(controllers.ListRoom)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/room", m.SetMiddlewareAuth(s.ListRoom)).Methods("GET", "OPTIONS")
 	// by room id
-	router.HandleFunc("/room/{id}", middlewares.SetMiddlewareAuth(controllers.OpenRoom)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/room/{id}", m.SetMiddlewareAuth(m.SetMiddlewareRoomMember(s.OpenRoom))).Methods("GET", "OPTIONS")
+
+	// group rooms
+	router.HandleFunc("/room/group", m.SetMiddlewareAuth(s.CreateGroup)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/room/{id}/members", m.SetMiddlewareAuth(m.SetMiddlewareRoomMember(s.AddMembers))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/room/{id}/members/{idUser}", m.SetMiddlewareAuth(m.SetMiddlewareRoomMember(s.RemoveMember))).Methods("DELETE", "OPTIONS")
+
+	// read receipts
+	router.HandleFunc("/room/{id}/read", m.SetMiddlewareAuth(m.SetMiddlewareRoomMember(s.MarkRead))).Methods("POST", "OPTIONS")
 
 	// send message
-	router.HandleFunc("/msg/{id}", middlewares.SetMiddlewareAuth(controllers.SendMsg)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/msg/{id}", m.SetMiddlewareAuth(m.SetMiddlewareRoomMember(s.SendMsg))).Methods("POST", "OPTIONS")
+
+	// a single message, here {id} is the message id
+	router.HandleFunc("/msg/{id}", m.SetMiddlewareAuth(m.SetMiddlewareMessageMember(s.EditMsg))).Methods("PATCH", "OPTIONS")
+	router.HandleFunc("/msg/{id}", m.SetMiddlewareAuth(m.SetMiddlewareMessageMember(s.DeleteMsg))).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/msg/{id}/edits", m.SetMiddlewareAuth(m.SetMiddlewareMessageMember(s.ListMsgEdits))).Methods("GET", "OPTIONS")
+	router.HandleFunc("/msg/{id}/reactions", m.SetMiddlewareAuth(m.SetMiddlewareMessageMember(s.AddReaction))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/msg/{id}/reactions/{emoji}", m.SetMiddlewareAuth(m.SetMiddlewareMessageMember(s.RemoveReaction))).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/msg/{id}/status", m.SetMiddlewareAuth(m.SetMiddlewareMessageMember(s.MessageStatus))).Methods("GET", "OPTIONS")
+
+	// search the messages of my rooms
+	router.HandleFunc("/search", m.SetMiddlewareAuth(s.Search)).Methods("GET", "OPTIONS")
+
+	// attachments, uploaded to a room then sent with a message
+	router.HandleFunc("/room/{id}/attachments", m.SetMiddlewareAuth(m.SetMiddlewareRoomMember(s.UploadAttachment))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/attachments/{id}", m.SetMiddlewareAuth(m.SetMiddlewareAttachmentMember(s.DownloadAttachment))).Methods("GET", "OPTIONS")
+	router.HandleFunc("/attachments/{id}/thumbnail", m.SetMiddlewareAuth(m.SetMiddlewareAttachmentMember(s.DownloadThumbnail))).Methods("GET", "OPTIONS")
+
+	// real-time updates
+	router.HandleFunc("/ws", m.SetMiddlewareStreamAuth(s.ServeWs)).Methods("GET")
+	router.HandleFunc("/events", m.SetMiddlewareStreamAuth(s.StreamEvents)).Methods("GET")
 
 	return router
 }
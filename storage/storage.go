@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by Open when there is no blob under the key.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps the content of the uploaded files, their metadata lives
+// in the database.
+type BlobStore interface {
+	// Put stores everything read from r under key, a failed Put leaves
+	// nothing behind.
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
@@ -0,0 +1,169 @@
+package thumbnails
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"time"
+
+	"github.com/f-chilmi/just-text-go/models"
+	"github.com/f-chilmi/just-text-go/repository"
+	"github.com/f-chilmi/just-text-go/storage"
+)
+
+// the thumbnails fit in a square of Size pixels
+const Size = 256
+
+// images larger than this many pixels are not decoded at all
+const maxPixels = 24 << 20
+
+// number of attachments waiting for the worker before new ones are left to
+// the next sweep
+const queueSize = 256
+
+// number of pending attachments picked up by a sweep
+const sweepBatch = 100
+
+var errTooLarge = errors.New("image too large")
+
+// Worker makes the thumbnails of the image attachments in the background.
+// Uploads are queued as they come, and the pending ones that were missed
+// (full queue, restart) are picked up by a periodic sweep.
+type Worker struct {
+	attachments repository.AttachmentRepository
+	blobs       storage.BlobStore
+	queue       chan int64
+}
+
+func NewWorker(attachments repository.AttachmentRepository, blobs storage.BlobStore) *Worker {
+	return &Worker{
+		attachments: attachments,
+		blobs:       blobs,
+		queue:       make(chan int64, queueSize),
+	}
+}
+
+// Enqueue asks for the thumbnail of the attachment, it never blocks.
+func (w *Worker) Enqueue(idAttachment int64) {
+	select {
+	case w.queue <- idAttachment:
+	default:
+	}
+}
+
+// Run processes the queue and sweeps the pending attachments every
+// interval, it is meant to run in its own goroutine.
+func (w *Worker) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	w.sweep()
+	for {
+		select {
+		case id := <-w.queue:
+			attachment, err := w.attachments.GetAttachment(id)
+			if err != nil {
+				log.Printf("unable to load attachment %d. %v", id, err)
+				continue
+			}
+			w.process(attachment)
+		case <-ticker.C:
+			w.sweep()
+		}
+	}
+}
+
+func (w *Worker) sweep() {
+	pending, err := w.attachments.ListPendingThumbnails(sweepBatch)
+	if err != nil {
+		log.Printf("unable to list the pending thumbnails. %v", err)
+		return
+	}
+	for _, attachment := range pending {
+		w.process(attachment)
+	}
+}
+
+// process makes the thumbnail of a pending attachment and records its
+// dimensions. Images that cannot be decoded are marked as failed so they
+// are not retried.
+func (w *Worker) process(a models.Attachment) {
+	if a.ThumbnailStatus != models.ThumbnailPending {
+		return
+	}
+
+	width, height, thumb, err := w.render(a)
+	if err != nil {
+		log.Printf("unable to make the thumbnail of attachment %d. %v", a.ID, err)
+		if _, err := w.attachments.SetThumbnail(a.ID, width, height, "", models.ThumbnailFailed); err != nil {
+			log.Printf("unable to save the thumbnail of attachment %d. %v", a.ID, err)
+		}
+		return
+	}
+
+	key := a.StorageKey + ".thumb"
+	if err := w.blobs.Put(key, bytes.NewReader(thumb)); err != nil {
+		// the blob store may be back on the next sweep
+		log.Printf("unable to store the thumbnail of attachment %d. %v", a.ID, err)
+		return
+	}
+
+	updated, err := w.attachments.SetThumbnail(a.ID, width, height, key, models.ThumbnailReady)
+	if err != nil || updated == 0 {
+		// the attachment was deleted meanwhile
+		if err != nil {
+			log.Printf("unable to save the thumbnail of attachment %d. %v", a.ID, err)
+		}
+		if err := w.blobs.Delete(key); err != nil {
+			log.Printf("unable to delete blob %s. %v", key, err)
+		}
+	}
+}
+
+// render returns the dimensions of the image and its encoded thumbnail.
+func (w *Worker) render(a models.Attachment) (int, int, []byte, error) {
+	config, err := w.decodeConfig(a.StorageKey)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if config.Width*config.Height > maxPixels {
+		return config.Width, config.Height, nil, errTooLarge
+	}
+
+	blob, err := w.blobs.Open(a.StorageKey)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	defer blob.Close()
+
+	// gif only gives its first frame
+	src, _, err := image.Decode(blob)
+	if err != nil {
+		return config.Width, config.Height, nil, err
+	}
+	thumb := Resize(src, Size)
+
+	var buf bytes.Buffer
+	if a.ThumbnailMimeType() == "image/jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&buf, thumb)
+	}
+	return config.Width, config.Height, buf.Bytes(), err
+}
+
+// decodeConfig only reads the header of the image.
+func (w *Worker) decodeConfig(key string) (image.Config, error) {
+	blob, err := w.blobs.Open(key)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer blob.Close()
+
+	config, _, err := image.DecodeConfig(blob)
+	return config, err
+}